@@ -3,6 +3,7 @@ module github.com/paultyng/terraform-static-registry
 go 1.15
 
 require (
+	github.com/BurntSushi/toml v0.3.1
 	github.com/hashicorp/go-cleanhttp v0.5.2-0.20190406162018-d3fcbee8e181
 	github.com/hashicorp/go-version v1.2.1
 	github.com/hashicorp/hcl/v2 v2.7.0
@@ -10,7 +11,11 @@ require (
 	github.com/mitchellh/cli v1.1.2
 	github.com/shurcooL/githubv4 v0.0.0-20200928013246-d292edc3691b
 	github.com/shurcooL/graphql v0.0.0-20200928012149-18c5c3165e3a // indirect
+	github.com/yuin/goldmark v1.4.13
+	github.com/zclconf/go-cty v1.2.0
 	golang.org/x/crypto v0.0.0-20201016220609-9e8e0b390897
 	golang.org/x/net v0.0.0-20201024042810-be3efd7ff127 // indirect
 	golang.org/x/oauth2 v0.0.0-20200902213428-5d25da1a8d43
+	golang.org/x/sys v0.0.0-20200930185726-fdedc70b468f
+	gopkg.in/yaml.v3 v3.0.0-20200313102051-9f266ea9e77c
 )
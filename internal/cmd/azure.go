@@ -0,0 +1,310 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Static Web Apps rewrite the protocol paths to the .json files with a route
+// per document, as routes can only have trailing wildcards. Blob Storage
+// static websites have no rewrites, so when uploading to a storage account
+// the registry is written with the exact layout and the content types are
+// set on upload.
+
+const azureStorageVersion = "2019-12-12"
+
+// staticWebAppConfig is a staticwebapp.config.json file, see
+// https://docs.microsoft.com/en-us/azure/static-web-apps/configuration
+type staticWebAppConfig struct {
+	Routes    []staticWebAppRoute `json:"routes"`
+	MimeTypes map[string]string   `json:"mimeTypes"`
+}
+
+type staticWebAppRoute struct {
+	Route   string            `json:"route"`
+	Rewrite string            `json:"rewrite,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+func (cmd *generateCmd) generateAzure(ctx context.Context, rd registryData, srv server) error {
+	if srv.Account != "" {
+		return cmd.generateSite(ctx, rd, exactLayout, srv)
+	}
+
+	err := cmd.generateSite(ctx, rd, rewriteLayout, srv)
+	if err != nil {
+		return err
+	}
+
+	cmd.ui.Info("\t[azure] writing static web app configuration...")
+	err = cmd.writeJSONFile(filepath.Join(srv.Output, "staticwebapp.config.json"), staticWebAppConfig{
+		Routes: staticWebAppRoutes(rd, srv, cmd.fullAPI),
+		MimeTypes: map[string]string{
+			".json": "application/json",
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("unable to write static web app configuration: %w", err)
+	}
+
+	return nil
+}
+
+// staticWebAppRoutes rewrites the protocol path of every registry document to
+// its file in the rewrite layout.
+func staticWebAppRoutes(rd registryData, srv server, fullAPI bool) []staticWebAppRoute {
+	routes := []staticWebAppRoute{}
+	rewrite := func(route, file string) {
+		routes = append(routes, staticWebAppRoute{
+			Route:   srv.urlPath(route),
+			Rewrite: srv.urlPath(file),
+		})
+	}
+
+	for k := range rd.ProviderVersions {
+		rewrite(providerVersionsPath(k), rewriteLayout.providerVersionsFile(k))
+	}
+	for k := range rd.Downloads {
+		rewrite(providerDownloadPath(k), rewriteLayout.providerDownloadFile(k))
+	}
+	for k := range rd.ModuleVersions {
+		rewrite(moduleVersionsPath(k), rewriteLayout.moduleVersionsFile(k))
+	}
+	for k, v := range rd.ModuleDownloads {
+		routes = append(routes, staticWebAppRoute{
+			Route:   srv.urlPath(moduleDownloadPath(k)),
+			Rewrite: srv.urlPath(rewriteLayout.moduleDownloadFile(k)),
+			Headers: map[string]string{
+				"X-Terraform-Get": v.Location,
+			},
+		})
+	}
+
+	if fullAPI {
+		namespaces := map[string]bool{}
+		for k, vi := range rd.ProviderVersions {
+			versions := sortedVersions(vi)
+			if latestVersion(versions) == nil {
+				continue
+			}
+			ns, name := strings.ToLower(k.Namespace), strings.ToLower(k.Name)
+			dir := path.Join("providers/v1", ns, name)
+			rewrite(dir, path.Join(dir, "index.json"))
+			for _, v := range versions {
+				rewrite(path.Join(dir, v.Original()), path.Join(dir, v.Original()+".json"))
+			}
+			namespaces[ns] = true
+		}
+		for ns := range namespaces {
+			rewrite(path.Join("providers/v1", ns), path.Join("providers/v1", ns, "index.json"))
+		}
+	}
+
+	sort.Slice(routes, func(i, j int) bool {
+		return routes[i].Route < routes[j].Route
+	})
+	return routes
+}
+
+func (cmd *generateCmd) publishAzure(ctx context.Context, srv server) error {
+	if srv.Account == "" {
+		return nil
+	}
+
+	key := os.Getenv("AZURE_STORAGE_KEY")
+	if key == "" {
+		return authError(fmt.Errorf("AZURE_STORAGE_KEY is required to upload to storage account %q", srv.Account))
+	}
+	keyBytes, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return fmt.Errorf("unable to decode AZURE_STORAGE_KEY: %w", err)
+	}
+
+	endpoint := srv.Endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://%s.blob.core.windows.net", srv.Account)
+	}
+
+	cmd.ui.Info(fmt.Sprintf("\t[azure] uploading to container %q...", srv.Container))
+	return cmd.publishRemote(srv, remoteStore{
+		get: func(name string) ([]byte, error) {
+			return getAzureBlob(ctx, cmd.httpClient, endpoint, srv.Account, keyBytes, srv.Container, name)
+		},
+		put: func(name string, body []byte) error {
+			return putAzureBlob(ctx, cmd.httpClient, endpoint, srv.Account, keyBytes, srv.Container, name, body)
+		},
+		del: func(name string) error {
+			return deleteAzureBlob(ctx, cmd.httpClient, endpoint, srv.Account, keyBytes, srv.Container, name)
+		},
+	})
+}
+
+// siteContentType returns the content type of a generated file, files
+// without an extension are registry documents at their exact paths.
+func siteContentType(name string) string {
+	ext := path.Ext(name)
+	switch ext {
+	case "", ".json":
+		return "application/json"
+	case ".html":
+		return "text/html; charset=utf-8"
+	}
+	if ct := mime.TypeByExtension(ext); ct != "" {
+		return ct
+	}
+	return "application/octet-stream"
+}
+
+// walkSiteFiles calls fn with the slash separated relative path and contents
+// of every file in dir.
+func walkSiteFiles(dir string, fn func(name string, body []byte) error) error {
+	return filepath.Walk(dir, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+		if rel == manifestFile {
+			return nil
+		}
+		body, err := ioutil.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("unable to read file %q: %w", file, err)
+		}
+		return fn(filepath.ToSlash(rel), body)
+	})
+}
+
+// getAzureBlob downloads a blob.
+func getAzureBlob(ctx context.Context, client *http.Client, endpoint, account string, key []byte, container, name string) ([]byte, error) {
+	resp, err := azureBlobRequest(ctx, client, http.MethodGet, endpoint, account, key, container, name, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to download blob %q: %w", name, err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, upstreamError(fmt.Errorf("unable to download blob %q: %w", name, err))
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return respBody, nil
+	case http.StatusNotFound:
+		return nil, fmt.Errorf("unable to download blob %q: %w", name, errNotFound)
+	}
+	return nil, statusError(resp.StatusCode, fmt.Errorf("unable to download blob %q, status %d: %s", name, resp.StatusCode, respBody))
+}
+
+// putAzureBlob uploads a block blob.
+func putAzureBlob(ctx context.Context, client *http.Client, endpoint, account string, key []byte, container, name string, body []byte) error {
+	resp, err := azureBlobRequest(ctx, client, http.MethodPut, endpoint, account, key, container, name, map[string]string{
+		"x-ms-blob-type":          "BlockBlob",
+		"x-ms-blob-content-type":  siteContentType(name),
+		"x-ms-blob-cache-control": "max-age=300",
+	}, body)
+	if err != nil {
+		return fmt.Errorf("unable to upload blob %q: %w", name, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		respBody, _ := ioutil.ReadAll(resp.Body)
+		return statusError(resp.StatusCode, fmt.Errorf("unable to upload blob %q, status %d: %s", name, resp.StatusCode, respBody))
+	}
+	return nil
+}
+
+// deleteAzureBlob deletes a blob, a blob that does not exist is not an error.
+func deleteAzureBlob(ctx context.Context, client *http.Client, endpoint, account string, key []byte, container, name string) error {
+	resp, err := azureBlobRequest(ctx, client, http.MethodDelete, endpoint, account, key, container, name, nil, nil)
+	if err != nil {
+		return fmt.Errorf("unable to delete blob %q: %w", name, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted && resp.StatusCode != http.StatusNotFound {
+		respBody, _ := ioutil.ReadAll(resp.Body)
+		return statusError(resp.StatusCode, fmt.Errorf("unable to delete blob %q, status %d: %s", name, resp.StatusCode, respBody))
+	}
+	return nil
+}
+
+// azureBlobRequest sends a blob request using Shared Key authorization, see
+// https://docs.microsoft.com/en-us/rest/api/storageservices/authorize-with-shared-key
+func azureBlobRequest(ctx context.Context, client *http.Client, method, endpoint, account string, key []byte, container, name string, headers map[string]string, body []byte) (*http.Response, error) {
+	u, err := url.Parse(strings.TrimSuffix(endpoint, "/") + "/" + container + "/" + name)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse blob URL: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("unable to create request: %w", err)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("x-ms-date", time.Now().UTC().Format(http.TimeFormat))
+	req.Header.Set("x-ms-version", azureStorageVersion)
+
+	var msHeaders []string
+	for k := range req.Header {
+		if lk := strings.ToLower(k); strings.HasPrefix(lk, "x-ms-") {
+			msHeaders = append(msHeaders, lk+":"+req.Header.Get(k))
+		}
+	}
+	sort.Strings(msHeaders)
+
+	contentLength := ""
+	if len(body) > 0 {
+		contentLength = strconv.Itoa(len(body))
+	}
+
+	stringToSign := strings.Join([]string{
+		method,
+		"", // Content-Encoding
+		"", // Content-Language
+		contentLength,
+		"", // Content-MD5
+		"", // Content-Type
+		"", // Date
+		"", // If-Modified-Since
+		"", // If-Match
+		"", // If-None-Match
+		"", // If-Unmodified-Since
+		"", // Range
+		strings.Join(msHeaders, "\n"),
+		"/" + account + u.EscapedPath(),
+	}, "\n")
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("SharedKey %s:%s", account, base64.StdEncoding.EncodeToString(mac.Sum(nil))))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, upstreamError(err)
+	}
+	return resp, nil
+}
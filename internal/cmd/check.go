@@ -0,0 +1,337 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/hashicorp/go-cleanhttp"
+	"golang.org/x/crypto/openpgp"
+)
+
+type checkCmd struct {
+	commonCmd
+
+	// also send a HEAD request to every download URL
+	headDownloads bool
+
+	httpClient *http.Client
+	client     *registryClient
+}
+
+func (cmd *checkCmd) Synopsis() string {
+	return "checks a deployed registry"
+}
+
+func (cmd *checkCmd) Help() string {
+	return `Usage: tfstaticregistry check [-head] URL [namespace[/name] ...]
+
+  Checks a deployed registry the way terraform init would use it: service
+  discovery, then every version and platform of each provider, verifying
+  the SHA256SUMS signatures with the published keys.
+
+  Providers are given as namespace/name, or as a namespace to check every
+  provider in its listing, which requires a registry generated with
+  -full-api. With no providers the ones in the configuration are checked.
+
+  A bearer token for private registries is read from TF_TOKEN_<hostname>,
+  with the periods in the hostname replaced by underscores.`
+}
+
+func (cmd *checkCmd) Flags() *flag.FlagSet {
+	fs := flag.NewFlagSet("check", flag.ContinueOnError)
+	fs.BoolVar(&cmd.headDownloads, "head", false, "also check every download URL with a HEAD request")
+	cmd.configFlags(fs)
+	return fs
+}
+
+func (cmd *checkCmd) Run(args []string) int {
+	fs := cmd.Flags()
+	err := fs.Parse(args)
+	if err != nil {
+		cmd.ui.Error(fmt.Sprintf("unable to parse flags: %s", err))
+		return exitCodeConfig
+	}
+	if fs.NArg() == 0 {
+		cmd.ui.Error("a registry URL is required")
+		return exitCodeConfig
+	}
+
+	return cmd.run(func() error {
+		return cmd.runInternal(fs.Arg(0), fs.Args()[1:])
+	})
+}
+
+// checkResult is the outcome of checking one provider platform, or of a
+// provider if the error occurred before its platforms were known.
+type checkResult struct {
+	Provider string
+	Version  string
+	OS       string
+	Arch     string
+	Err      error
+}
+
+func (r checkResult) String() string {
+	if r.Version == "" {
+		return r.Provider
+	}
+	if r.OS == "" {
+		return fmt.Sprintf("%s %s", r.Provider, r.Version)
+	}
+	return fmt.Sprintf("%s %s %s_%s", r.Provider, r.Version, r.OS, r.Arch)
+}
+
+func (cmd *checkCmd) runInternal(registry string, providers []string) error {
+	ctx := context.Background()
+
+	if cmd.httpClient == nil {
+		cmd.httpClient = cleanhttp.DefaultClient()
+	}
+
+	if len(providers) == 0 {
+		var err error
+		providers, err = cmd.configProviders()
+		if err != nil {
+			return err
+		}
+	}
+
+	cmd.ui.Info(fmt.Sprintf("Checking %s...\n", registry))
+
+	client, wk, err := newRegistryClient(ctx, cmd.httpClient, registry)
+	if err != nil {
+		return err
+	}
+	cmd.client = client
+
+	cmd.ui.Info(fmt.Sprintf("\tproviders.v1:\t%s", client.providersURL))
+	if wk.ModulesV1 != "" {
+		cmd.ui.Info(fmt.Sprintf("\tmodules.v1:\t%s", wk.ModulesV1))
+	}
+	if wk.LoginV1 != nil {
+		cmd.ui.Info(fmt.Sprintf("\tlogin.v1:\t%s", wk.LoginV1.Client))
+	}
+
+	results := []checkResult{}
+	for _, p := range providers {
+		names := []string{p}
+		if !strings.Contains(p, "/") {
+			names, err = cmd.client.namespaceProviders(ctx, p)
+			if err != nil {
+				results = append(results, checkResult{Provider: p, Err: err})
+				cmd.ui.Error(fmt.Sprintf("\t[%q] FAIL: %s", p, err))
+				continue
+			}
+		}
+		for _, name := range names {
+			results = append(results, cmd.checkProvider(ctx, name)...)
+		}
+	}
+
+	failed := 0
+	var firstErr error
+	for _, r := range results {
+		if r.Err != nil {
+			if firstErr == nil {
+				firstErr = r.Err
+			}
+			failed++
+		}
+	}
+
+	cmd.ui.Info(fmt.Sprintf("\n%d passed, %d failed", len(results)-failed, failed))
+	switch {
+	case failed == 0:
+		return nil
+	case failed < len(results):
+		return partialError(fmt.Errorf("%d of %d checks failed", failed, len(results)))
+	}
+	// every check failed, so report why the first one did
+	return fmt.Errorf("%d of %d checks failed: %w", failed, len(results), firstErr)
+}
+
+// configProviders returns the provider addresses in the registry
+// configuration, as they are published.
+func (cmd *checkCmd) configProviders() ([]string, error) {
+	if cmd.configPath == "" {
+		cmd.configPath = defaultConfigFile()
+	}
+	if _, err := os.Stat(cmd.configPath); os.IsNotExist(err) {
+		return nil, configError(fmt.Errorf("no providers given and no %s found", cmd.configPath))
+	}
+	conf, err := cmd.loadConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	providers := []string{}
+	for _, p := range conf.Providers {
+		name := p.String()
+		if p.Registry != nil {
+			// registry sourced providers keep their source namespace and name
+			parts := strings.Split(p.Registry.Source, "/")
+			name = strings.Join(parts[len(parts)-2:], "/")
+		}
+		providers = append(providers, strings.ToLower(name))
+	}
+	if len(providers) == 0 {
+		return nil, configError(fmt.Errorf("no providers found in %s", cmd.configPath))
+	}
+	return providers, nil
+}
+
+func (cmd *checkCmd) checkProvider(ctx context.Context, name string) []checkResult {
+	fail := func(r checkResult) []checkResult {
+		cmd.ui.Error(fmt.Sprintf("\t[%q] FAIL %s: %s", name, r, r.Err))
+		return []checkResult{r}
+	}
+
+	parts := strings.Split(name, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return fail(checkResult{Provider: name, Err: fmt.Errorf("malformed provider %q, expected namespace/name", name)})
+	}
+
+	versions, err := cmd.client.providerVersions(ctx, name)
+	if err != nil {
+		return fail(checkResult{Provider: name, Err: fmt.Errorf("unable to get versions: %w", err)})
+	}
+	if len(versions.Versions) == 0 {
+		return fail(checkResult{Provider: name, Err: fmt.Errorf("no versions published")})
+	}
+
+	cmd.ui.Info(fmt.Sprintf("\t[%q] checking %d versions...", name, len(versions.Versions)))
+
+	// the SHA256SUMS file is usually shared by every platform of a version
+	sums := map[string]map[string]string{}
+
+	results := []checkResult{}
+	for _, v := range versions.Versions {
+		if len(v.Platforms) == 0 {
+			r := checkResult{Provider: name, Version: v.Version, Err: fmt.Errorf("no platforms published")}
+			results = append(results, fail(r)...)
+			continue
+		}
+		for _, plat := range v.Platforms {
+			r := checkResult{
+				Provider: name,
+				Version:  v.Version,
+				OS:       plat.OS,
+				Arch:     plat.Arch,
+			}
+			r.Err = cmd.checkDownload(ctx, r, sums)
+			if r.Err != nil {
+				results = append(results, fail(r)...)
+				continue
+			}
+			results = append(results, r)
+		}
+	}
+
+	if len(results) > 0 {
+		cmd.ui.Info(fmt.Sprintf("\t[%q] checked %d platforms", name, len(results)))
+	}
+	return results
+}
+
+func (cmd *checkCmd) checkDownload(ctx context.Context, r checkResult, sums map[string]map[string]string) error {
+	downloadURL, d, err := cmd.client.providerDownload(ctx, r.Provider, r.Version, r.OS, r.Arch)
+	if err != nil {
+		return fmt.Errorf("unable to get download information: %w", err)
+	}
+
+	switch {
+	case d.OS != r.OS || d.Arch != r.Arch:
+		return fmt.Errorf("download is for %s_%s", d.OS, d.Arch)
+	case d.Filename == "" || d.DownloadURL == "" || d.Shasum == "":
+		return fmt.Errorf("download is missing a filename, download_url or shasum")
+	case d.ShasumsURL == "" || d.ShasumsSignatureURL == "":
+		return fmt.Errorf("download is missing a shasums_url or shasums_signature_url")
+	case len(d.SigningKeys.GPGPublicKeys) == 0:
+		return fmt.Errorf("download has no signing keys")
+	}
+
+	sumsURL, err := downloadURL.Parse(d.ShasumsURL)
+	if err != nil {
+		return fmt.Errorf("invalid shasums_url %q: %w", d.ShasumsURL, err)
+	}
+
+	fileSums, ok := sums[sumsURL.String()]
+	if !ok {
+		fileSums, err = cmd.verifySHASUMS(ctx, downloadURL, d)
+		if err != nil {
+			return err
+		}
+		sums[sumsURL.String()] = fileSums
+	}
+
+	if sum, ok := fileSums[d.Filename]; !ok {
+		return fmt.Errorf("%q is not listed in the SHA256SUMS file", d.Filename)
+	} else if sum != d.Shasum {
+		return fmt.Errorf("shasum %q does not match %q in the SHA256SUMS file", d.Shasum, sum)
+	}
+
+	if cmd.headDownloads {
+		fileURL, err := downloadURL.Parse(d.DownloadURL)
+		if err != nil {
+			return fmt.Errorf("invalid download_url %q: %w", d.DownloadURL, err)
+		}
+		err = cmd.client.head(ctx, fileURL)
+		if err != nil {
+			return fmt.Errorf("unable to reach download_url: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// verifySHASUMS downloads the SHA256SUMS file and checks its signature with
+// the published keys, it returns the sums by file name.
+func (cmd *checkCmd) verifySHASUMS(ctx context.Context, downloadURL *url.URL, d providerDownloadIndex) (map[string]string, error) {
+	sumsURL, err := downloadURL.Parse(d.ShasumsURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid shasums_url %q: %w", d.ShasumsURL, err)
+	}
+	sigURL, err := downloadURL.Parse(d.ShasumsSignatureURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid shasums_signature_url %q: %w", d.ShasumsSignatureURL, err)
+	}
+
+	sumsBody, err := cmd.client.get(ctx, sumsURL)
+	if err != nil {
+		return nil, fmt.Errorf("unable to download SHA256SUMS: %w", err)
+	}
+	sigBody, err := cmd.client.get(ctx, sigURL)
+	if err != nil {
+		return nil, fmt.Errorf("unable to download SHA256SUMS signature: %w", err)
+	}
+
+	var keyRing openpgp.EntityList
+	for _, k := range d.SigningKeys.GPGPublicKeys {
+		keys, err := openpgp.ReadArmoredKeyRing(strings.NewReader(k.ASCIIArmor))
+		if err != nil {
+			return nil, fmt.Errorf("unable to read signing key %q: %w", k.KeyID, err)
+		}
+		keyRing = append(keyRing, keys...)
+	}
+
+	_, err = openpgp.CheckDetachedSignature(keyRing, bytes.NewReader(sumsBody), bytes.NewReader(sigBody))
+	if err != nil {
+		return nil, fmt.Errorf("unable to verify SHA256SUMS signature: %w", err)
+	}
+
+	parsed, err := parseSHASUMS(sumsBody)
+	if err != nil {
+		return nil, err
+	}
+	fileSums := map[string]string{}
+	for _, s := range parsed {
+		fileSums[s.File] = s.Sum
+	}
+	return fileSums, nil
+}
@@ -0,0 +1,289 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/mitchellh/cli"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+)
+
+const testFilename = "terraform-provider-test_1.0.0_linux_amd64.zip"
+
+// testRegistry is a deployed registry serving one provider version for
+// linux_amd64, signed by key.
+type testRegistry struct {
+	key *openpgp.Entity
+
+	// overrides of the served documents, empty to serve the valid one
+	discovery            string
+	discoveryContentType string
+	versions             string
+	shasum               string
+	sums                 string
+	signature            string
+
+	// bearer token required by every request
+	token string
+}
+
+func newTestKey(t *testing.T) *openpgp.Entity {
+	t.Helper()
+	key, err := openpgp.NewEntity("test", "", "test@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func armoredPublicKey(t *testing.T, key *openpgp.Entity) string {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := armor.Encode(&buf, openpgp.PublicKeyType, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = key.Serialize(w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	return buf.String()
+}
+
+func detachSign(t *testing.T, key *openpgp.Entity, message string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	err := openpgp.DetachSign(&buf, key, strings.NewReader(message), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func (tr testRegistry) handler(t *testing.T) http.Handler {
+	sum := strings.Repeat("a", 64)
+	sums := fmt.Sprintf("%s  %s\n", sum, testFilename)
+	if tr.sums != "" {
+		sums = tr.sums
+	}
+	signature := detachSign(t, tr.key, sums)
+	if tr.signature != "" {
+		signature = tr.signature
+	}
+	shasum := sum
+	if tr.shasum != "" {
+		shasum = tr.shasum
+	}
+
+	discovery := `{"providers.v1": "/v1/providers/"}`
+	if tr.discovery != "" {
+		discovery = tr.discovery
+	}
+	versions := `{"versions": [{"version": "1.0.0", "protocols": ["5.0"], "platforms": [{"os": "linux", "arch": "amd64"}]}]}`
+	if tr.versions != "" {
+		versions = tr.versions
+	}
+	download, err := json.Marshal(providerDownloadIndex{
+		Protocols:           []string{"5.0"},
+		OS:                  "linux",
+		Arch:                "amd64",
+		Filename:            testFilename,
+		DownloadURL:         "/files/" + testFilename,
+		ShasumsURL:          "/files/SHA256SUMS",
+		ShasumsSignatureURL: "/files/SHA256SUMS.sig",
+		Shasum:              shasum,
+		SigningKeys: signingKeys{
+			GPGPublicKeys: []gpgPublicKey{{
+				KeyID:      tr.key.PrimaryKey.KeyIdString(),
+				ASCIIArmor: armoredPublicKey(t, tr.key),
+			}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	discoveryContentType := "application/json"
+	if tr.discoveryContentType != "" {
+		discoveryContentType = tr.discoveryContentType
+	}
+
+	docs := map[string]struct {
+		body        string
+		contentType string
+	}{
+		"/.well-known/terraform.json":                        {discovery, discoveryContentType},
+		"/v1/providers/test/test/versions":                   {versions, "application/json"},
+		"/v1/providers/test/test/1.0.0/download/linux/amd64": {string(download), "application/json"},
+		"/files/SHA256SUMS":                                  {sums, "text/plain"},
+		"/files/SHA256SUMS.sig":                              {signature, "application/octet-stream"},
+		"/files/" + testFilename:                             {"", "application/zip"},
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if tr.token != "" && r.Header.Get("Authorization") != "Bearer "+tr.token {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		doc, ok := docs[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", doc.contentType)
+		fmt.Fprint(w, doc.body)
+	})
+}
+
+func TestCheck(t *testing.T) {
+	key := newTestKey(t)
+	otherKey := newTestKey(t)
+
+	for _, c := range []struct {
+		name     string
+		registry testRegistry
+		code     int
+		output   string
+	}{
+		{
+			name:     "valid",
+			registry: testRegistry{},
+			code:     0,
+			output:   "1 passed, 0 failed",
+		},
+		{
+			name:     "bad signature",
+			registry: testRegistry{signature: detachSign(t, key, "tampered\n")},
+			code:     exitCodeError,
+			output:   "unable to verify SHA256SUMS signature",
+		},
+		{
+			name:     "signed by another key",
+			registry: testRegistry{signature: detachSign(t, otherKey, fmt.Sprintf("%s  %s\n", strings.Repeat("a", 64), testFilename))},
+			code:     exitCodeError,
+			output:   "unable to verify SHA256SUMS signature",
+		},
+		{
+			name:     "shasum mismatch",
+			registry: testRegistry{shasum: strings.Repeat("b", 64)},
+			code:     exitCodeError,
+			output:   "does not match",
+		},
+		{
+			name:     "discovery not served as JSON",
+			registry: testRegistry{discoveryContentType: "text/plain; charset=utf-8"},
+			code:     exitCodeError,
+			output:   "Terraform requires application/json",
+		},
+		{
+			name:     "no providers.v1 service",
+			registry: testRegistry{discovery: `{"modules.v1": "/v1/modules/"}`},
+			code:     exitCodeError,
+			output:   "has no providers.v1 service",
+		},
+		{
+			name:     "no versions",
+			registry: testRegistry{versions: `{"versions": []}`},
+			code:     exitCodeError,
+			output:   "no versions published",
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			c.registry.key = key
+			srv := httptest.NewServer(c.registry.handler(t))
+			defer srv.Close()
+
+			ui := cli.NewMockUi()
+			cmd := &checkCmd{
+				commonCmd:     commonCmd{ui: ui},
+				headDownloads: true,
+				httpClient:    srv.Client(),
+			}
+
+			code := cmd.Run([]string{srv.URL, "test/test"})
+			if code != c.code {
+				t.Fatalf("expected exit code %d, got %d\n%s%s", c.code, code, ui.OutputWriter, ui.ErrorWriter)
+			}
+			output := ui.OutputWriter.String() + ui.ErrorWriter.String()
+			if !strings.Contains(output, c.output) {
+				t.Fatalf("expected output to contain %q\n%s", c.output, output)
+			}
+		})
+	}
+}
+
+func TestCheckToken(t *testing.T) {
+	key := newTestKey(t)
+	srv := httptest.NewServer(testRegistry{key: key, token: "secret"}.handler(t))
+	defer srv.Close()
+
+	// send requests for a hyphenated hostname to the test server
+	client := srv.Client()
+	transport := client.Transport.(*http.Transport).Clone()
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, network, srv.Listener.Addr().String())
+	}
+	client.Transport = transport
+
+	for _, c := range []struct {
+		name string
+		env  string
+		code int
+	}{
+		{"double underscore", "TF_TOKEN_my__registry_example_com", 0},
+		{"literal hyphen", "TF_TOKEN_my-registry_example_com", 0},
+		{"missing", "TF_TOKEN_other_example_com", exitCodeAuth},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			os.Setenv(c.env, "secret")
+			defer os.Unsetenv(c.env)
+
+			ui := cli.NewMockUi()
+			cmd := &checkCmd{
+				commonCmd:  commonCmd{ui: ui},
+				httpClient: client,
+			}
+
+			code := cmd.Run([]string{"http://my-registry.example.com", "test/test"})
+			if code != c.code {
+				t.Fatalf("expected exit code %d, got %d\n%s%s", c.code, code, ui.OutputWriter, ui.ErrorWriter)
+			}
+		})
+	}
+}
+
+func TestCheckPartial(t *testing.T) {
+	key := newTestKey(t)
+	srv := httptest.NewServer(testRegistry{key: key}.handler(t))
+	defer srv.Close()
+
+	ui := cli.NewMockUi()
+	cmd := &checkCmd{
+		commonCmd:  commonCmd{ui: ui},
+		httpClient: srv.Client(),
+	}
+
+	code := cmd.Run([]string{srv.URL, "test/test", "test/missing"})
+	if code != exitCodePartial {
+		t.Fatalf("expected exit code %d, got %d\n%s%s", exitCodePartial, code, ui.OutputWriter, ui.ErrorWriter)
+	}
+}
+
+func TestCheckFlags(t *testing.T) {
+	ui := cli.NewMockUi()
+	cmd := &checkCmd{commonCmd: commonCmd{ui: ui}}
+
+	code := cmd.Run([]string{})
+	if code != exitCodeConfig {
+		t.Fatalf("expected exit code %d, got %d", exitCodeConfig, code)
+	}
+}
@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"context"
+	"path/filepath"
+)
+
+// Cloudflare Pages limits the number of rules in a _headers file, see
+// https://developers.cloudflare.com/pages/platform/headers
+const cloudflareMaxHeaderRules = 100
+
+var cloudflareJSONHeaders = [][2]string{
+	{"Content-Type", "application/json"},
+	{"Access-Control-Allow-Origin", "*"},
+}
+
+func (cmd *generateCmd) generateCloudflare(ctx context.Context, rd registryData, srv server) error {
+	err := cmd.generateSite(ctx, rd, rewriteLayout, srv)
+	if err != nil {
+		return err
+	}
+
+	rules := []headerRule{
+		{Path: "/.well-known/terraform.json", Headers: cloudflareJSONHeaders},
+		{Path: srv.urlPath("providers/v1/:namespace/:name/versions"), Headers: cloudflareJSONHeaders},
+		{Path: srv.urlPath("providers/v1/:namespace/:name/:version/download/:os/:arch"), Headers: cloudflareJSONHeaders},
+		{Path: srv.urlPath("modules/v1/:namespace/:name/:system/versions"), Headers: cloudflareJSONHeaders},
+		{Path: srv.urlPath("modules/v1/:namespace/:name/:system/:version/download"), Headers: cloudflareJSONHeaders},
+	}
+
+	if moduleRules := moduleHeaderRules(rd, srv); len(rules)+len(moduleRules) <= cloudflareMaxHeaderRules {
+		rules = append(rules, moduleRules...)
+	} else {
+		cmd.ui.Warn("\t[cloudflare] too many module versions for header rules, X-Terraform-Get is only set in the response body")
+	}
+
+	cmd.ui.Info("\t[cloudflare] writing headers file...")
+	err = cmd.writeHeadersFile(filepath.Join(srv.Output, "_headers"), rules)
+	if err != nil {
+		return err
+	}
+
+	cmd.ui.Info("\t[cloudflare] writing redirects file...")
+	return cmd.writeRedirectsFile(filepath.Join(srv.Output, "_redirects"), srv)
+}
@@ -1,15 +1,126 @@
 package cmd
 
-import "fmt"
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// configFile is the registry configuration in the working directory.
+const configFile = "registry.hcl"
 
 type config struct {
 	Providers []provider `hcl:"provider,block"`
+	Modules   []module   `hcl:"module,block"`
+
+	Login *loginV1 `hcl:"login,block"`
+
+	Servers []server `hcl:"server,block"`
+}
+
+// server is a target the registry is published to, one generate run can
+// publish to several servers.
+type server struct {
+	Type   string `hcl:"type,label"`
+	Output string `hcl:"output,optional"`
+	Format string `hcl:"format,optional"`
+
+	// BasePath is the path of the registry on the host, service discovery
+	// is always at the root
+	BasePath string `hcl:"base_path,optional"`
+
+	// BaseURL makes the service discovery URLs absolute, for a registry on
+	// a different host
+	BaseURL string `hcl:"base_url,optional"`
+
+	// cloud storage
+	Bucket    string `hcl:"bucket,optional"`
+	Prefix    string `hcl:"prefix,optional"`
+	Region    string `hcl:"region,optional"`
+	Account   string `hcl:"account,optional"`
+	Container string `hcl:"container,optional"`
+	Endpoint  string `hcl:"endpoint,optional"`
+
+	// github pages
+	CNAME string `hcl:"cname,optional"`
+	Push  string `hcl:"push,optional"`
+
+	// target is the output directory a staging directory will replace
+	target string
+}
+
+var serverTypes = []string{"netlify", "cloudflare", "nginx", "apache", "caddy", "github-pages", "azure", "gcs", "s3", "directory"}
+
+var formats = []string{"registry", "network-mirror", "filesystem-mirror"}
+
+func (srv server) Validate() error {
+	if !contains(serverTypes, srv.Type) {
+		return fmt.Errorf("server type %q not supported", srv.Type)
+	}
+	if srv.Format != "" && !contains(formats, srv.Format) {
+		return fmt.Errorf("format %q not supported", srv.Format)
+	}
+	// a plain directory has no rewrites to serve the registry protocol
+	if srv.Type == "directory" && (srv.Format == "" || srv.Format == "registry") {
+		return fmt.Errorf("the directory server type is only supported for the mirror formats")
+	}
+
+	if srv.BaseURL != "" {
+		u, err := url.Parse(srv.BaseURL)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("base URL %q must be an absolute URL", srv.BaseURL)
+		}
+	}
+
+	switch srv.Type {
+	case "gcs", "s3":
+		if srv.Bucket == "" {
+			return fmt.Errorf("a bucket is required for the %s server type", srv.Type)
+		}
+	}
+
+	switch srv.Type {
+	case "gcs", "s3":
+		prefix, basePath := strings.Trim(srv.Prefix, "/"), strings.Trim(srv.BasePath, "/")
+		if prefix != "" && basePath != "" && prefix != basePath {
+			return fmt.Errorf("prefix %q must match the base path %q, or be used without one", srv.Prefix, srv.BasePath)
+		}
+	}
+	return nil
+}
+
+func (l loginV1) Validate() error {
+	if l.Client == "" {
+		return fmt.Errorf("a client ID is required for login")
+	}
+	for _, gt := range l.GrantTypes {
+		switch gt {
+		case "authz_code", "password":
+		default:
+			return fmt.Errorf("login grant type %q is not supported", gt)
+		}
+	}
+	if l.Authz == "" && (len(l.GrantTypes) == 0 || contains(l.GrantTypes, "authz_code")) {
+		return fmt.Errorf("an authorization URL is required for the authz_code grant type")
+	}
+	if n := len(l.Ports); n != 0 && n != 2 {
+		return fmt.Errorf("login ports must be a range of two ports, got %d", n)
+	}
+	return nil
 }
 
 type provider struct {
 	Namespace string `hcl:"namespace,label"`
 	Name      string `hcl:"name,label"`
 
+	// Metadata for the extended registry API
+	Description string `hcl:"description,optional"`
+	Tier        string `hcl:"tier,optional"`
+
+	// OnInvalidRelease is error, warn or ignore, defaulting to warn, or
+	// error with -strict
+	OnInvalidRelease string `hcl:"on_invalid_release,optional"`
+
 	// Sources
 	Manual   *manualSource   `hcl:"manual,block"`
 	GitHub   *gitHubSource   `hcl:"github,block"`
@@ -29,22 +140,45 @@ type registrySource struct {
 	Source string `hcl:"source"`
 }
 
+type module struct {
+	Namespace string `hcl:"namespace,label"`
+	Name      string `hcl:"name,label"`
+	System    string `hcl:"system,label"`
+
+	// OnInvalidRelease is error, warn or ignore, defaulting to warn, or
+	// error with -strict
+	OnInvalidRelease string `hcl:"on_invalid_release,optional"`
+
+	// Sources
+	GitHub   *gitHubModuleSource `hcl:"github,block"`
+	Archives []moduleArchive     `hcl:"archive,block"`
+}
+
+func (m module) String() string {
+	return fmt.Sprintf("%s/%s/%s", m.Namespace, m.Name, m.System)
+}
+
+type gitHubModuleSource struct {
+	Repository string `hcl:"repository"`
+
+	// Archive uses the GitHub tarball for a tag instead of a git source address
+	Archive bool `hcl:"archive,optional"`
+}
+
+type moduleArchive struct {
+	Version string `hcl:"version"`
+	URL     string `hcl:"url"`
+}
+
 type manualSource struct {
 	// TODO: support a manual source
 }
 
-func (conf *config) Validate() error {
-	for _, p := range conf.Providers {
-		if p.Namespace == "" {
-			return fmt.Errorf("a blank namespace is not allowed")
-		}
-		if p.Name == "" {
-			return fmt.Errorf("a blank name is not allowed")
-		}
-
-		if p.GitHub == nil && p.Registry == nil && p.Manual == nil {
-			return fmt.Errorf("a source block of github, registry, or manual is required for provider %q", p)
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
 		}
 	}
-	return nil
+	return false
 }
@@ -0,0 +1,227 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html/template"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/shurcooL/githubv4"
+	"github.com/yuin/goldmark"
+)
+
+// docsDirs are the documentation locations of a provider repository, in
+// order of preference, see https://www.terraform.io/docs/registry/providers/docs.html
+var docsDirs = []string{"docs", "website/docs"}
+
+var docsCategories = map[string]string{
+	"resources":    "resources",
+	"data-sources": "data-sources",
+	"guides":       "guides",
+
+	// legacy website/docs layout
+	"r": "resources",
+	"d": "data-sources",
+}
+
+var docsExtensions = []string{".html.markdown", ".html.md", ".markdown", ".md"}
+
+// collectGitHubDocs reads the documentation tree of a repository at a tag.
+func (cmd *generateCmd) collectGitHubDocs(ctx context.Context, owner, name, tag string, k providerDownloadKey) ([]providerDoc, error) {
+	var q struct {
+		Repository struct {
+			Object struct {
+				Tree struct {
+					Entries []struct {
+						Name   string
+						Type   string
+						Object struct {
+							Blob struct {
+								Text string
+							} `graphql:"... on Blob"`
+							Tree struct {
+								Entries []struct {
+									Name   string
+									Type   string
+									Object struct {
+										Blob struct {
+											Text string
+										} `graphql:"... on Blob"`
+									}
+								}
+							} `graphql:"... on Tree"`
+						}
+					}
+				} `graphql:"... on Tree"`
+			} `graphql:"object(expression: $expression)"`
+		} `graphql:"repository(owner: $owner, name: $name)"`
+	}
+
+	for _, dir := range docsDirs {
+		variables := map[string]interface{}{
+			"owner":      githubv4.String(owner),
+			"name":       githubv4.String(name),
+			"expression": githubv4.String(tag + ":" + dir),
+		}
+		err := cmd.githubClient.Query(ctx, &q, variables)
+		if err != nil {
+			return nil, githubError(err)
+		}
+
+		entries := q.Repository.Object.Tree.Entries
+		if len(entries) == 0 {
+			continue
+		}
+
+		docs := []providerDoc{}
+		for _, e := range entries {
+			switch e.Type {
+			case "blob":
+				if doc, ok := newProviderDoc(k, path.Join(dir, e.Name), "", e.Object.Blob.Text); ok {
+					docs = append(docs, doc)
+				}
+			case "tree":
+				category, ok := docsCategories[e.Name]
+				if !ok {
+					continue
+				}
+				for _, child := range e.Object.Tree.Entries {
+					if child.Type != "blob" {
+						continue
+					}
+					if doc, ok := newProviderDoc(k, path.Join(dir, e.Name, child.Name), category, child.Object.Blob.Text); ok {
+						docs = append(docs, doc)
+					}
+				}
+			}
+		}
+		return docs, nil
+	}
+
+	return nil, nil
+}
+
+func newProviderDoc(k providerDownloadKey, file, category, content string) (providerDoc, bool) {
+	base := path.Base(file)
+	slug := ""
+	for _, ext := range docsExtensions {
+		if strings.HasSuffix(base, ext) {
+			slug = strings.TrimSuffix(base, ext)
+			break
+		}
+	}
+	if slug == "" {
+		return providerDoc{}, false
+	}
+
+	if category == "" {
+		if slug != "index" {
+			return providerDoc{}, false
+		}
+		category = "overview"
+	}
+
+	frontMatter, body := splitFrontMatter(content)
+
+	return providerDoc{
+		ID:          fmt.Sprintf("%s/%s/%s/%s/%s", k.Namespace, k.Name, k.Version, category, slug),
+		Title:       slug,
+		Path:        file,
+		Slug:        slug,
+		Category:    category,
+		Subcategory: frontMatter["subcategory"],
+		Language:    "hcl",
+
+		Content: body,
+	}, true
+}
+
+// splitFrontMatter separates the YAML front matter of a docs page from its
+// Markdown, only simple string values are parsed.
+func splitFrontMatter(content string) (map[string]string, string) {
+	values := map[string]string{}
+	if !strings.HasPrefix(content, "---\n") {
+		return values, content
+	}
+	end := strings.Index(content[4:], "\n---")
+	if end < 0 {
+		return values, content
+	}
+	for _, line := range strings.Split(content[4:4+end], "\n") {
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		values[strings.TrimSpace(parts[0])] = strings.Trim(strings.TrimSpace(parts[1]), `"'`)
+	}
+	body := content[4+end+len("\n---"):]
+	return values, strings.TrimPrefix(body, "\n")
+}
+
+var docsPageTemplate = template.Must(template.New("doc").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{ .Namespace }}/{{ .Name }} {{ .Version }}: {{ .Doc.Title }}</title>
+</head>
+<body>
+<nav>{{ .Namespace }}/{{ .Name }} {{ .Version }}</nav>
+<main>
+{{ .Body }}
+</main>
+</body>
+</html>
+`))
+
+func (cmd *generateCmd) generateProviderDocs(ctx context.Context, rd registryData, outputDir string) error {
+	for k, details := range rd.ProviderDetails {
+		for ver, vd := range details.Versions {
+			if len(vd.Docs) == 0 {
+				continue
+			}
+
+			dir := filepath.Join(
+				outputDir,
+				"providers/v1",
+				strings.ToLower(k.Namespace), strings.ToLower(k.Name),
+				ver, "docs",
+			)
+
+			for _, doc := range vd.Docs {
+				var body bytes.Buffer
+				err := goldmark.Convert([]byte(doc.Content), &body)
+				if err != nil {
+					return fmt.Errorf("unable to render %q: %w", doc.Path, err)
+				}
+
+				var page bytes.Buffer
+				err = docsPageTemplate.Execute(&page, map[string]interface{}{
+					"Namespace": k.Namespace,
+					"Name":      k.Name,
+					"Version":   ver,
+					"Doc":       doc,
+					// goldmark does not render raw HTML by default
+					"Body": template.HTML(body.String()),
+				})
+				if err != nil {
+					return fmt.Errorf("unable to render %q: %w", doc.Path, err)
+				}
+
+				err = cmd.writeFile(filepath.Join(dir, doc.Category, doc.Slug+".html"), page.Bytes())
+				if err != nil {
+					return err
+				}
+			}
+
+			err := cmd.writeJSONFile(filepath.Join(dir, "index.json"), vd.Docs)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
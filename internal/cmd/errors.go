@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Exit codes of the commands, any error without a class exits with 1.
+const (
+	exitCodeError    = 1
+	exitCodeConfig   = 2
+	exitCodeAuth     = 3
+	exitCodeUpstream = 4
+	exitCodePartial  = 5
+	exitCodeInvalid  = 6
+)
+
+// exitError classifies an error with the exit code of the command.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
+// configError is an invalid configuration or invalid flags.
+func configError(err error) error {
+	return &exitError{code: exitCodeConfig, err: err}
+}
+
+// authError is a missing token or one rejected by an upstream service.
+func authError(err error) error {
+	return &exitError{code: exitCodeAuth, err: err}
+}
+
+// upstreamError is a network error or an unexpected response from GitHub, a
+// registry or a storage service.
+func upstreamError(err error) error {
+	return &exitError{code: exitCodeUpstream, err: err}
+}
+
+// partialError is a run that failed after some of its work succeeded, such
+// as some servers being published or some checks passing.
+func partialError(err error) error {
+	return &exitError{code: exitCodePartial, err: err}
+}
+
+// invalidReleaseError is a release that can not be collected, failing the
+// run with -strict or on_invalid_release set to error.
+func invalidReleaseError(err error) error {
+	return &exitError{code: exitCodeInvalid, err: err}
+}
+
+// statusError classifies an error for an unexpected HTTP status.
+func statusError(status int, err error) error {
+	if status == http.StatusUnauthorized || status == http.StatusForbidden {
+		return authError(err)
+	}
+	return upstreamError(err)
+}
+
+// githubError classifies an error from a GitHub GraphQL query, keeping the
+// authError of a githubTransport.
+func githubError(err error) error {
+	var exitErr *exitError
+	if errors.As(err, &exitErr) {
+		return err
+	}
+	return upstreamError(err)
+}
+
+// githubTransport fails requests rejected by GitHub with an authError, as the
+// GraphQL client only reports the status in its message. A 403 is left to
+// githubError, as GitHub also uses it for rate limits.
+type githubTransport struct {
+	base http.RoundTripper
+}
+
+func (t *githubTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		resp.Body.Close()
+		return nil, authError(fmt.Errorf("GitHub returned %s, check GITHUB_TOKEN", resp.Status))
+	}
+	return resp, nil
+}
+
+// exitCode returns the exit code for the outermost classified error.
+func exitCode(err error) int {
+	var exitErr *exitError
+	if errors.As(err, &exitErr) {
+		return exitErr.code
+	}
+	return exitCodeError
+}
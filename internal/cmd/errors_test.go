@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/shurcooL/githubv4"
+)
+
+func TestExitCode(t *testing.T) {
+	errTest := errors.New("test")
+
+	for _, c := range []struct {
+		name string
+		err  error
+		code int
+	}{
+		{"unclassified", errTest, exitCodeError},
+		{"config", configError(errTest), exitCodeConfig},
+		{"auth", authError(errTest), exitCodeAuth},
+		{"upstream", upstreamError(errTest), exitCodeUpstream},
+		{"partial", partialError(errTest), exitCodePartial},
+		{"invalid release", invalidReleaseError(errTest), exitCodeInvalid},
+		{"wrapped", fmt.Errorf("unable to collect: %w", authError(errTest)), exitCodeAuth},
+		{"outermost", partialError(fmt.Errorf("%w, 1 of 2 servers were generated", upstreamError(errTest))), exitCodePartial},
+		{"status 401", statusError(http.StatusUnauthorized, errTest), exitCodeAuth},
+		{"status 403", statusError(http.StatusForbidden, errTest), exitCodeAuth},
+		{"status 404", statusError(http.StatusNotFound, errTest), exitCodeUpstream},
+		{"status 500", statusError(http.StatusInternalServerError, errTest), exitCodeUpstream},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			code := exitCode(c.err)
+			if code != c.code {
+				t.Fatalf("expected exit code %d, got %d", c.code, code)
+			}
+		})
+	}
+}
+
+func TestGitHubError(t *testing.T) {
+	for _, c := range []struct {
+		name   string
+		status int
+		code   int
+	}{
+		{"unauthorized", http.StatusUnauthorized, exitCodeAuth},
+		{"rate limited", http.StatusForbidden, exitCodeUpstream},
+		{"unavailable", http.StatusBadGateway, exitCodeUpstream},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(c.status)
+			}))
+			defer srv.Close()
+
+			httpClient := srv.Client()
+			httpClient.Transport = &githubTransport{base: httpClient.Transport}
+			client := githubv4.NewEnterpriseClient(srv.URL, httpClient)
+
+			var q struct {
+				Viewer struct {
+					Login githubv4.String
+				}
+			}
+			err := client.Query(context.Background(), &q, nil)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+
+			code := exitCode(githubError(err))
+			if code != c.code {
+				t.Fatalf("expected exit code %d, got %d: %s", c.code, code, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func (cmd *generateCmd) generateFilesystemMirror(ctx context.Context, rd registryData, outputDir string) error {
+	cmd.ui.Info("\t[filesystem-mirror] downloading provider archives...")
+	for k, d := range rd.Downloads {
+		host, err := cmd.providerHostname(providerVersionsKey{
+			Namespace: k.Namespace,
+			Name:      k.Name,
+		}, rd)
+		if err != nil {
+			return err
+		}
+
+		// packed layout, see https://www.terraform.io/docs/commands/cli-config.html#filesystem_mirror
+		file := filepath.Join(
+			outputDir,
+			strings.ToLower(host),
+			strings.ToLower(k.Namespace), strings.ToLower(k.Name),
+			fmt.Sprintf("terraform-provider-%s_%s_%s_%s.zip", strings.ToLower(k.Name), k.Version, k.OS, k.Arch),
+		)
+
+		if d.Shasum != "" {
+			if sum, err := fileSHA256(file); err == nil && sum == d.Shasum {
+				cmd.ui.Info(fmt.Sprintf("\t\t%q already downloaded", file))
+				cmd.markWritten(file)
+				continue
+			}
+		}
+
+		cmd.ui.Info(fmt.Sprintf("\t\tdownloading %q...", d.DownloadURL))
+		err = downloadFile(ctx, cmd.httpClient, d.DownloadURL, file, d.Shasum)
+		if err != nil {
+			return fmt.Errorf("unable to download \"%s/%s\" %q \"%s/%s\": %w", k.Namespace, k.Name, k.Version, k.OS, k.Arch, err)
+		}
+		cmd.markWritten(file)
+	}
+
+	return nil
+}
+
+// downloadFile downloads url to file, verifying the SHA256 of the contents if
+// shasum is not empty.
+func downloadFile(ctx context.Context, client *http.Client, url, file, shasum string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("unable to create request: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return upstreamError(fmt.Errorf("unable to GET file: %w", err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return statusError(resp.StatusCode, fmt.Errorf("unexpected status code %d for %q", resp.StatusCode, url))
+	}
+
+	dir := filepath.Dir(file)
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return fmt.Errorf("unable to make directory %q: %w", dir, err)
+	}
+
+	tmp := file + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("unable to create file %q: %w", tmp, err)
+	}
+	defer os.Remove(tmp)
+
+	h := sha256.New()
+	_, err = io.Copy(io.MultiWriter(f, h), resp.Body)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("unable to write file %q: %w", tmp, err)
+	}
+
+	if sum := hex.EncodeToString(h.Sum(nil)); shasum != "" && !strings.EqualFold(sum, shasum) {
+		return fmt.Errorf("checksum mismatch for %q, expected %q, got %q", url, shasum, sum)
+	}
+
+	err = os.Rename(tmp, file)
+	if err != nil {
+		return fmt.Errorf("unable to move file %q: %w", file, err)
+	}
+	return nil
+}
+
+func fileSHA256(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	_, err = io.Copy(h, f)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,167 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Google Cloud Storage static sites have no rewrites, so the registry is
+// written with the exact layout and each object's content type is set on
+// upload.
+
+type gcsObject struct {
+	Name         string `json:"name"`
+	ContentType  string `json:"contentType"`
+	CacheControl string `json:"cacheControl"`
+}
+
+func (cmd *generateCmd) generateGCS(ctx context.Context, rd registryData, srv server) error {
+	return cmd.generateSite(ctx, rd, exactLayout, srv)
+}
+
+func (cmd *generateCmd) publishGCS(ctx context.Context, srv server) error {
+	endpoint := srv.Endpoint
+	if endpoint == "" {
+		endpoint = "https://storage.googleapis.com"
+	}
+
+	// a token is not required for local emulators like fake-gcs-server
+	token := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN")
+	if token == "" && srv.Endpoint == "" {
+		return authError(fmt.Errorf("GOOGLE_OAUTH_ACCESS_TOKEN is required to upload to bucket %q", srv.Bucket))
+	}
+
+	cmd.ui.Info(fmt.Sprintf("\t[gcs] uploading to bucket %q...", srv.Bucket))
+	// the prefix is the base path, so it is already part of the names
+	return cmd.publishRemote(srv, remoteStore{
+		get: func(name string) ([]byte, error) {
+			return getGCSObject(ctx, cmd.httpClient, endpoint, token, srv.Bucket, name)
+		},
+		put: func(name string, body []byte) error {
+			return putGCSObject(ctx, cmd.httpClient, endpoint, token, srv.Bucket, gcsObject{
+				Name:         name,
+				ContentType:  siteContentType(name),
+				CacheControl: "public, max-age=300",
+			}, body)
+		},
+		del: func(name string) error {
+			return deleteGCSObject(ctx, cmd.httpClient, endpoint, token, srv.Bucket, name)
+		},
+	})
+}
+
+// getGCSObject downloads the contents of an object, see
+// https://cloud.google.com/storage/docs/json_api/v1/objects/get
+func getGCSObject(ctx context.Context, client *http.Client, endpoint, token, bucket, name string) ([]byte, error) {
+	u := fmt.Sprintf("%s/storage/v1/b/%s/o/%s?alt=media", strings.TrimSuffix(endpoint, "/"), url.PathEscape(bucket), url.PathEscape(name))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create request: %w", err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, upstreamError(fmt.Errorf("unable to download object %q: %w", name, err))
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, upstreamError(fmt.Errorf("unable to download object %q: %w", name, err))
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return respBody, nil
+	case http.StatusNotFound:
+		return nil, fmt.Errorf("unable to download object %q: %w", name, errNotFound)
+	}
+	return nil, statusError(resp.StatusCode, fmt.Errorf("unable to download object %q, status %d: %s", name, resp.StatusCode, respBody))
+}
+
+// deleteGCSObject deletes an object, an object that does not exist is not an
+// error, see https://cloud.google.com/storage/docs/json_api/v1/objects/delete
+func deleteGCSObject(ctx context.Context, client *http.Client, endpoint, token, bucket, name string) error {
+	u := fmt.Sprintf("%s/storage/v1/b/%s/o/%s", strings.TrimSuffix(endpoint, "/"), url.PathEscape(bucket), url.PathEscape(name))
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, u, nil)
+	if err != nil {
+		return fmt.Errorf("unable to create request: %w", err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return upstreamError(fmt.Errorf("unable to delete object %q: %w", name, err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		respBody, _ := ioutil.ReadAll(resp.Body)
+		return statusError(resp.StatusCode, fmt.Errorf("unable to delete object %q, status %d: %s", name, resp.StatusCode, respBody))
+	}
+	return nil
+}
+
+// putGCSObject uploads an object with its metadata using a multipart upload, see
+// https://cloud.google.com/storage/docs/uploading-objects#rest-upload-objects
+func putGCSObject(ctx context.Context, client *http.Client, endpoint, token, bucket string, obj gcsObject, body []byte) error {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+
+	metadata, err := json.Marshal(obj)
+	if err != nil {
+		return fmt.Errorf("unable to marshal object metadata: %w", err)
+	}
+
+	part, err := mw.CreatePart(textproto.MIMEHeader{"Content-Type": {"application/json; charset=UTF-8"}})
+	if err != nil {
+		return err
+	}
+	part.Write(metadata)
+
+	part, err = mw.CreatePart(textproto.MIMEHeader{"Content-Type": {obj.ContentType}})
+	if err != nil {
+		return err
+	}
+	part.Write(body)
+
+	err = mw.Close()
+	if err != nil {
+		return err
+	}
+
+	u := fmt.Sprintf("%s/upload/storage/v1/b/%s/o?uploadType=multipart", strings.TrimSuffix(endpoint, "/"), url.PathEscape(bucket))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, &buf)
+	if err != nil {
+		return fmt.Errorf("unable to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "multipart/related; boundary="+mw.Boundary())
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return upstreamError(fmt.Errorf("unable to upload object %q: %w", obj.Name, err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := ioutil.ReadAll(resp.Body)
+		return statusError(resp.StatusCode, fmt.Errorf("unable to upload object %q, status %d: %s", obj.Name, resp.StatusCode, respBody))
+	}
+	return nil
+}
@@ -4,12 +4,13 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/hashicorp/go-cleanhttp"
-	"github.com/hashicorp/hcl/v2/hclsimple"
 	"github.com/shurcooL/githubv4"
 	"golang.org/x/oauth2"
 )
@@ -17,11 +18,49 @@ import (
 type generateCmd struct {
 	commonCmd
 
-	// required flags
-	serverType string
+	// server flags, these override any servers in the configuration
+	serverType     string
+	outputDir      string
+	format         string
+	azureAccount   string
+	azureContainer string
+	azureEndpoint  string
+	gcsBucket      string
+	gcsPrefix      string
+	gcsEndpoint    string
+	cname          string
+	pushRemote     string
+	basePath       string
+	baseURL        string
 
-	// required for locally built static sites, like netlify
-	outputDir string
+	// hostname used in mirror layouts for providers not sourced from another registry
+	hostname string
+
+	// generate the provider listing and metadata documents of the registry API
+	fullAPI bool
+
+	// render provider documentation pages
+	docs bool
+
+	// directory of templates overriding the default HTML index pages
+	templateDir string
+
+	// list stale files instead of deleting them, without replacing the
+	// output directory or publishing
+	dryRun bool
+
+	// fail on invalid releases instead of skipping them
+	strict bool
+
+	// files written for the server being generated, and the same files
+	// relative to the output directory as recorded in the manifest
+	written map[string]bool
+	files   []string
+
+	// text or json progress output, and the file the run is summarized in
+	logFormat  string
+	reportFile string
+	report     report
 
 	httpClient   *http.Client
 	githubClient *githubv4.Client
@@ -36,9 +75,29 @@ func (cmd *generateCmd) Help() string {
 }
 
 func (cmd *generateCmd) Flags() *flag.FlagSet {
-	fs := flag.NewFlagSet("generate", flag.ExitOnError)
+	fs := flag.NewFlagSet("generate", flag.ContinueOnError)
 	fs.StringVar(&cmd.serverType, "server", "", "type of server for the registry")
 	fs.StringVar(&cmd.outputDir, "output", "", "output directory for static site")
+	fs.StringVar(&cmd.format, "format", "", "output format: registry, network-mirror, or filesystem-mirror")
+	fs.StringVar(&cmd.hostname, "hostname", "", "hostname of the registry, used in mirror output formats")
+	fs.BoolVar(&cmd.fullAPI, "full-api", false, "also generate provider listing and metadata documents")
+	fs.BoolVar(&cmd.docs, "docs", false, "also render provider documentation pages, GitHub sources only")
+	fs.StringVar(&cmd.templateDir, "templates", "", "directory of templates overriding the HTML index and provider pages")
+	fs.StringVar(&cmd.azureAccount, "azure-account", "", "azure storage account to upload to, the key is read from AZURE_STORAGE_KEY")
+	fs.StringVar(&cmd.azureContainer, "azure-container", "", "azure blob container to upload to, defaults to $web")
+	fs.StringVar(&cmd.azureEndpoint, "azure-endpoint", "", "azure blob service endpoint, for example an Azurite instance")
+	fs.StringVar(&cmd.gcsBucket, "gcs-bucket", "", "google cloud storage bucket to upload to, the token is read from GOOGLE_OAUTH_ACCESS_TOKEN")
+	fs.StringVar(&cmd.gcsPrefix, "gcs-prefix", "", "prefix of the uploaded object names")
+	fs.StringVar(&cmd.gcsEndpoint, "gcs-endpoint", "", "google cloud storage endpoint, for example a fake-gcs-server instance")
+	fs.StringVar(&cmd.cname, "cname", "", "custom domain written to the CNAME file for github pages")
+	fs.StringVar(&cmd.pushRemote, "push", "", "git remote to force push the gh-pages branch to for github pages")
+	fs.StringVar(&cmd.basePath, "base-path", "", "path of the registry on the host, for example /terraform")
+	fs.BoolVar(&cmd.dryRun, "dry-run", false, "list stale files without deleting them, replacing the output directory or publishing")
+	fs.StringVar(&cmd.baseURL, "base-url", "", "absolute URL of the host used in service discovery, for example https://example.com")
+	fs.BoolVar(&cmd.strict, "strict", false, "fail on invalid releases instead of skipping them, unless on_invalid_release is set")
+	fs.StringVar(&cmd.logFormat, "log-format", "text", "format of the progress output: text or json")
+	fs.StringVar(&cmd.reportFile, "report", "", "file to write a JSON summary of the run to, defaults to report.json with -log-format json")
+	cmd.configFlags(fs)
 	return fs
 }
 
@@ -47,13 +106,38 @@ func (cmd *generateCmd) Run(args []string) int {
 	err := fs.Parse(args)
 	if err != nil {
 		cmd.ui.Error(fmt.Sprintf("unable to parse flags: %s", err))
-		return 1
+		return exitCodeConfig
+	}
+
+	switch cmd.logFormat {
+	case "text":
+	case "json":
+		cmd.ui = &jsonUi{cmd.ui}
+		if cmd.reportFile == "" {
+			cmd.reportFile = "report.json"
+		}
+	default:
+		cmd.ui.Error(fmt.Sprintf("log format %q not supported, expected text or json", cmd.logFormat))
+		return exitCodeConfig
 	}
 
 	return cmd.run(cmd.runInternal)
 }
 
 func (cmd *generateCmd) runInternal() error {
+	cmd.report = newReport()
+
+	err := cmd.generate()
+	if cmd.reportFile != "" {
+		reportErr := cmd.writeReport(err)
+		if err == nil {
+			err = reportErr
+		}
+	}
+	return err
+}
+
+func (cmd *generateCmd) generate() error {
 	cmd.ui.Info("")
 	ctx := context.Background()
 
@@ -62,43 +146,71 @@ func (cmd *generateCmd) runInternal() error {
 		return err
 	}
 
-	var conf config
-	err = hclsimple.DecodeFile("registry.hcl", nil, &conf)
+	conf, err := cmd.loadConfig()
 	if err != nil {
 		return err
 	}
-	err = conf.Validate()
+
+	servers, err := cmd.servers(cwd, conf)
+	if err != nil {
+		return configError(err)
+	}
+
+	for _, srv := range servers {
+		cmd.ui.Info(fmt.Sprintf("Server type:\t%s\nOutput dir:\t%s\nFormat:\t\t%s\n", srv.Type, srv.Output, srv.Format))
+	}
+
+	if conf.Login != nil {
+		cmd.ui.Warn("login.v1 is advertised in service discovery, but static hosts do not check tokens, serve the output with tfstaticregistry serve -token-file to require them\n")
+	}
+
+	r, err := cmd.collect(ctx, conf)
 	if err != nil {
 		return err
 	}
+	cmd.report.addRegistryData(r)
 
-	if cmd.serverType == "" {
-		if _, err := os.Stat(filepath.Join(cmd.outputDir, ".netlify")); err == nil {
-			cmd.serverType = "netlify"
-		} else if _, err := os.Stat(filepath.Join(cwd, ".netlify")); err == nil {
-			cmd.serverType = "netlify"
+	cmd.ui.Info("\nGenerating registry...\n")
+
+	for i, srv := range servers {
+		err = cmd.generateServer(ctx, r, srv)
+		if err != nil {
+			if i > 0 {
+				return partialError(fmt.Errorf("%w, %d of %d servers were generated", err, i, len(servers)))
+			}
+			return err
 		}
 	}
 
-	if cmd.serverType == "" {
-		return fmt.Errorf("a server type is required")
-	}
+	cmd.ui.Info("\nComplete!\n")
 
-	// server type specific defaults
-	switch cmd.serverType {
-	case "netlify":
-		// TODO: read this out of the netlify.toml file?
-		if cmd.outputDir == "" {
-			cmd.outputDir = "dist"
+	return nil
+}
+
+// servers returns the servers to generate, the flags override the servers in
+// the configuration.
+func (cmd *generateCmd) servers(cwd string, conf config) ([]server, error) {
+	servers := conf.Servers
+	if cmd.serverType != "" || cmd.outputDir != "" || len(servers) == 0 {
+		srv, err := cmd.flagServer(cwd)
+		if err != nil {
+			return nil, err
 		}
+		servers = []server{srv}
 	}
 
-	abs, err := filepath.Abs(cmd.outputDir)
-	cmd.outputDir, err = filepath.Rel(cwd, abs)
-	if err != nil {
-		return err
+	for i := range servers {
+		err := servers[i].setDefaults(cwd)
+		if err != nil {
+			return nil, err
+		}
 	}
+	return servers, nil
+}
 
+// collect gathers the registry data of every provider and module in the
+// configuration.
+func (cmd *generateCmd) collect(ctx context.Context, conf config) (registryData, error) {
 	cmd.httpClient = cleanhttp.DefaultClient()
 
 	if githubToken := os.Getenv("GITHUB_TOKEN"); githubToken != "" {
@@ -106,50 +218,295 @@ func (cmd *generateCmd) runInternal() error {
 			&oauth2.Token{AccessToken: githubToken},
 		)
 		httpClient := oauth2.NewClient(ctx, src)
+		httpClient.Transport = &githubTransport{base: httpClient.Transport}
 
 		cmd.githubClient = githubv4.NewClient(httpClient)
 	}
 
-	cmd.ui.Info(fmt.Sprintf("Output dir:\t%s\nServer type:\t%s\nGitHub:\t\t%t", cmd.outputDir, cmd.serverType, cmd.githubClient != nil))
+	cmd.ui.Info(fmt.Sprintf("GitHub:\t\t%t", cmd.githubClient != nil))
 
 	r := registryData{
 		ProviderVersions: map[providerVersionsKey]providerVersionsIndex{},
 		Downloads:        map[providerDownloadKey]providerDownloadIndex{},
+		Hostnames:        map[providerVersionsKey]string{},
+		ProviderDetails:  map[providerVersionsKey]providerDetails{},
+		ModuleVersions:   map[moduleVersionsKey]moduleVersionsIndex{},
+		ModuleDownloads:  map[moduleDownloadKey]moduleDownload{},
+
+		Login: conf.Login,
 	}
 
 	cmd.ui.Info("\nProcessing providers...\n")
 
 	for _, p := range conf.Providers {
+		var err error
 		switch {
 		case p.GitHub != nil:
 			err = cmd.collectGitHubProvider(ctx, p, r)
 			if err != nil {
-				return fmt.Errorf("unable to collect GitHub information for %q: %w", p, err)
+				return r, fmt.Errorf("unable to collect GitHub information for %q: %w", p, err)
 			}
 		case p.Registry != nil:
 			err = cmd.collectRegistryProvider(ctx, p, r)
 			if err != nil {
-				return fmt.Errorf("unable to collect registry information for %q: %w", p, err)
+				return r, fmt.Errorf("unable to collect registry information for %q: %w", p, err)
 			}
 		case p.Manual != nil:
-			return fmt.Errorf("manual source is not yet supported for %q", p)
+			return r, configError(fmt.Errorf("manual source is not yet supported for %q", p))
 		}
 
 	}
 
-	cmd.ui.Info("\nGenerating registry...\n")
+	if len(conf.Modules) > 0 {
+		cmd.ui.Info("\nProcessing modules...\n")
+	}
+
+	for _, m := range conf.Modules {
+		var err error
+		switch {
+		case m.GitHub != nil:
+			err = cmd.collectGitHubModule(ctx, m, r)
+			if err != nil {
+				return r, fmt.Errorf("unable to collect GitHub information for module %q: %w", m, err)
+			}
+		case len(m.Archives) > 0:
+			err = cmd.collectArchiveModule(ctx, m, r)
+			if err != nil {
+				return r, fmt.Errorf("unable to collect archives for module %q: %w", m, err)
+			}
+		}
+	}
+
+	return r, nil
+}
+
+// flagServer returns the server configured by flags, detecting Netlify if no
+// server type is given. Mirrors are written to a plain directory by default.
+func (cmd *generateCmd) flagServer(cwd string) (server, error) {
+	srv := server{
+		Type:      cmd.serverType,
+		Output:    cmd.outputDir,
+		Format:    cmd.format,
+		Account:   cmd.azureAccount,
+		Container: cmd.azureContainer,
+		Bucket:    cmd.gcsBucket,
+		Prefix:    cmd.gcsPrefix,
+		CNAME:     cmd.cname,
+		Push:      cmd.pushRemote,
+		BasePath:  cmd.basePath,
+		BaseURL:   cmd.baseURL,
+	}
+
+	switch {
+	case cmd.azureEndpoint != "":
+		srv.Endpoint = cmd.azureEndpoint
+	case cmd.gcsEndpoint != "":
+		srv.Endpoint = cmd.gcsEndpoint
+	}
+
+	if srv.Type == "" {
+		if _, err := os.Stat(filepath.Join(srv.Output, ".netlify")); err == nil {
+			srv.Type = "netlify"
+		} else if _, err := os.Stat(filepath.Join(cwd, ".netlify")); err == nil {
+			srv.Type = "netlify"
+		}
+	}
+
+	if srv.Type == "" && srv.Format != "" && srv.Format != "registry" {
+		srv.Type = "directory"
+	}
+
+	if srv.Type == "" {
+		return server{}, fmt.Errorf("a server type is required")
+	}
+
+	return srv, srv.Validate()
+}
+
+// setDefaults fills in the server type specific defaults and makes the output
+// directory relative to the working directory.
+func (srv *server) setDefaults(cwd string) error {
+	if srv.Format == "" {
+		srv.Format = "registry"
+	}
+
+	// objects are served under the prefix, so it is the base path of the
+	// registry while service discovery stays at the root of the bucket
+	if (srv.Type == "gcs" || srv.Type == "s3") && srv.BasePath == "" {
+		srv.BasePath = srv.Prefix
+	}
+
+	srv.BasePath = strings.Trim(srv.BasePath, "/")
+	if srv.BasePath != "" {
+		srv.BasePath = "/" + srv.BasePath
+	}
+	srv.BaseURL = strings.TrimSuffix(srv.BaseURL, "/")
 
-	switch cmd.serverType {
+	switch srv.Type {
 	case "netlify":
-		err = cmd.generateNetlify(ctx, r)
-		if err != nil {
-			return fmt.Errorf("unable to generate netlify server: %w", err)
+		if srv.Output == "" {
+			var err error
+			srv.Output, err = readNetlifyPublishDir(cwd)
+			if err != nil {
+				return err
+			}
+		}
+	case "azure":
+		if srv.Container == "" {
+			srv.Container = "$web"
 		}
+	case "s3":
+		if srv.Region == "" {
+			srv.Region = "us-east-1"
+		}
+	}
+
+	if srv.Output == "" {
+		srv.Output = "dist"
+	}
+
+	abs, err := filepath.Abs(srv.Output)
+	if err != nil {
+		return err
+	}
+	srv.Output, err = filepath.Rel(cwd, abs)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (cmd *generateCmd) generateServer(ctx context.Context, rd registryData, srv server) error {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case canStage(cwd, srv):
+		err = cmd.generateStaged(ctx, rd, srv)
+	case cmd.dryRun:
+		err = cmd.generateDryRun(ctx, rd, srv)
 	default:
-		return fmt.Errorf("server type %q not supported", cmd.serverType)
+		cmd.ui.Warn(fmt.Sprintf("\t[%s] output directory %q contains the working directory, writing in place", srv.Type, srv.Output))
+		err = cmd.generateOutput(ctx, rd, srv)
+	}
+	if err != nil {
+		return err
 	}
 
-	cmd.ui.Info("\nComplete!\n")
+	if srv.Format != "registry" {
+		return nil
+	}
 
+	// storage services are still read to list their stale objects
+	switch srv.Type {
+	case "github-pages":
+		if cmd.dryRun {
+			return nil
+		}
+		err = cmd.publishGitHubPages(ctx, srv)
+	case "azure":
+		err = cmd.publishAzure(ctx, srv)
+	case "gcs":
+		err = cmd.publishGCS(ctx, srv)
+	case "s3":
+		err = cmd.publishS3(ctx, srv)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to publish %s server: %w", srv.Type, err)
+	}
 	return nil
 }
+
+// generateStaged generates the server in a staging directory and only
+// replaces the output directory once it is complete.
+func (cmd *generateCmd) generateStaged(ctx context.Context, rd registryData, srv server) error {
+	staged, err := stageOutput(srv)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(staged.Output)
+
+	err = cmd.generateOutput(ctx, rd, staged)
+	if err != nil {
+		return err
+	}
+
+	err = cmd.validateStaging(staged)
+	if err != nil {
+		return fmt.Errorf("unable to validate %s server: %w", srv.Type, err)
+	}
+
+	if cmd.dryRun {
+		cmd.ui.Info(fmt.Sprintf("\t[%s] dry run, discarding staging directory...", srv.Type))
+		return nil
+	}
+
+	cmd.ui.Info(fmt.Sprintf("\t[%s] moving staging directory to %q...", srv.Type, srv.Output))
+	return cmd.swapStaging(staged)
+}
+
+// generateDryRun generates an output directory that can't be staged into an
+// empty temporary directory, so only the stale files are listed.
+func (cmd *generateCmd) generateDryRun(ctx context.Context, rd registryData, srv server) error {
+	dir, err := ioutil.TempDir("", "tfstaticregistry-dry-run-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	srv.target = srv.Output
+	srv.Output = dir
+	return cmd.generateOutput(ctx, rd, srv)
+}
+
+// generateOutput writes the server files and prunes stale ones.
+func (cmd *generateCmd) generateOutput(ctx context.Context, rd registryData, srv server) error {
+	cmd.written = map[string]bool{}
+	cmd.files = nil
+
+	var err error
+	switch srv.Format {
+	case "registry":
+		switch srv.Type {
+		case "netlify":
+			err = cmd.generateNetlify(ctx, rd, srv)
+		case "cloudflare":
+			err = cmd.generateCloudflare(ctx, rd, srv)
+		case "nginx", "apache", "caddy":
+			err = cmd.generateSelfHosted(ctx, rd, srv)
+		case "github-pages":
+			err = cmd.generateGitHubPages(ctx, rd, srv)
+		case "azure":
+			err = cmd.generateAzure(ctx, rd, srv)
+		case "gcs":
+			err = cmd.generateGCS(ctx, rd, srv)
+		case "s3":
+			err = cmd.generateS3(ctx, rd, srv)
+		default:
+			return fmt.Errorf("server type %q not supported", srv.Type)
+		}
+		if err != nil {
+			return fmt.Errorf("unable to generate %s server: %w", srv.Type, err)
+		}
+	case "network-mirror":
+		err = cmd.generateNetworkMirror(ctx, rd, srv.registryDir())
+		if err != nil {
+			return fmt.Errorf("unable to generate network mirror: %w", err)
+		}
+	case "filesystem-mirror":
+		err = cmd.generateFilesystemMirror(ctx, rd, srv.registryDir())
+		if err != nil {
+			return fmt.Errorf("unable to generate filesystem mirror: %w", err)
+		}
+	default:
+		return fmt.Errorf("format %q not supported", srv.Format)
+	}
+
+	stale, err := cmd.prune(srv)
+	if err != nil {
+		return fmt.Errorf("unable to prune output directory %q: %w", srv.targetDir(), err)
+	}
+	return cmd.report.addServer(srv, cmd.written, stale)
+}
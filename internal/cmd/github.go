@@ -17,7 +17,7 @@ func (cmd *generateCmd) collectGitHubProvider(ctx context.Context, p provider, r
 	cmd.ui.Info(fmt.Sprintf("\t[%q] collecting GitHub information...", p))
 
 	if cmd.githubClient == nil {
-		return fmt.Errorf("no GitHub client configured, please specify api token")
+		return authError(fmt.Errorf("no GitHub client configured, please specify api token"))
 	}
 
 	repoParts := strings.Split(p.GitHub.Repository, "/")
@@ -60,6 +60,7 @@ func (cmd *generateCmd) collectGitHubProvider(ctx context.Context, p provider, r
 		TagName       string
 		IsPrerelease  bool
 		IsDraft       bool
+		PublishedAt   githubv4.DateTime
 		ReleaseAssets struct {
 			PageInfo pageInfo
 			Nodes    []releaseAsset
@@ -68,7 +69,9 @@ func (cmd *generateCmd) collectGitHubProvider(ctx context.Context, p provider, r
 
 	var q struct {
 		Repository struct {
-			Releases struct {
+			Description string
+			URL         string `graphql:"url"`
+			Releases    struct {
 				PageInfo pageInfo
 				Nodes    []release
 			} `graphql:"releases(first: 100, orderBy: { field: CREATED_AT, direction: DESC }, after: $releasesCursor)"`
@@ -85,10 +88,15 @@ func (cmd *generateCmd) collectGitHubProvider(ctx context.Context, p provider, r
 		Warnings: []string{},
 	}
 
+	details := providerDetails{
+		Tier:     p.Tier,
+		Versions: map[string]providerVersionDetails{},
+	}
+
 	for {
 		err := cmd.githubClient.Query(ctx, &q, variables)
 		if err != nil {
-			return err
+			return githubError(err)
 		}
 
 		// check if any releases have multiple pages of assets, not yet supported...
@@ -100,20 +108,33 @@ func (cmd *generateCmd) collectGitHubProvider(ctx context.Context, p provider, r
 				platforms    []platform
 				assetsByName = map[string]releaseAsset{}
 				sums         []shasum
+				vd           providerVersionDetails
 			)
 
-			cmd.ui.Info(fmt.Sprintf("\t[%q] processing tag %q...", p, r.TagName))
+			cmd.log(logEvent{
+				Level:    "info",
+				Action:   "collect",
+				Provider: p.String(),
+				Version:  r.TagName,
+				Message:  fmt.Sprintf("\t[%q] processing tag %q...", p, r.TagName),
+			})
 
 			if r.ReleaseAssets.PageInfo.HasNextPage {
 				return fmt.Errorf("release %q has over 100 assets, this is not yet supported", r.TagName)
 			}
 			ver := strings.TrimPrefix(r.TagName, "v")
 			if _, err := version.NewSemver(ver); err != nil {
-				cmd.ui.Warn(fmt.Sprintf("\t\t[%q] skipping %q, not valid semver: %s", p, r.TagName, err))
+				err = cmd.invalidRelease(p.OnInvalidRelease, logEvent{Provider: p.String(), Version: r.TagName, Reason: fmt.Sprintf("not valid semver: %s", err)})
+				if err != nil {
+					return err
+				}
 				goto NextRelease
 			}
 			if l := len(r.ReleaseAssets.Nodes); l == 0 {
-				cmd.ui.Warn(fmt.Sprintf("\t\t[%q] skipping %q, no release assets", p, r.TagName))
+				err = cmd.invalidRelease(p.OnInvalidRelease, logEvent{Provider: p.String(), Version: r.TagName, Reason: "no release assets"})
+				if err != nil {
+					return err
+				}
 				goto NextRelease
 			}
 
@@ -130,31 +151,46 @@ func (cmd *generateCmd) collectGitHubProvider(ctx context.Context, p provider, r
 				assetsByName[ra.Name] = ra
 			}
 			if sumsAsset == nil {
-				cmd.ui.Warn(fmt.Sprintf("\t[%q] skipping %q, no SHASUMS asset found", p, r.TagName))
+				err = cmd.invalidRelease(p.OnInvalidRelease, logEvent{Provider: p.String(), Version: r.TagName, Reason: "no SHASUMS asset found"})
+				if err != nil {
+					return err
+				}
 				goto NextRelease
 			}
 			if sigAsset == nil {
-				cmd.ui.Warn(fmt.Sprintf("\t[%q] skipping %q, no signature asset found", p, r.TagName))
+				err = cmd.invalidRelease(p.OnInvalidRelease, logEvent{Provider: p.String(), Version: r.TagName, Reason: "no signature asset found"})
+				if err != nil {
+					return err
+				}
 				goto NextRelease
 			}
 
 			sums, err = downloadSHASUMS(ctx, cmd.httpClient, sumsAsset.DownloadURL)
 			if err != nil {
-				cmd.ui.Warn(fmt.Sprintf("\t[%q] skipping %q, unable to download SHASUMS asset: %s", p, r.TagName, err))
+				err = cmd.invalidRelease(p.OnInvalidRelease, logEvent{Provider: p.String(), Version: r.TagName, Reason: fmt.Sprintf("unable to download SHASUMS asset: %s", err)})
+				if err != nil {
+					return err
+				}
 				goto NextRelease
 			}
 
 			for _, sum := range sums {
 				ra, ok := assetsByName[sum.File]
 				if !ok {
-					cmd.ui.Warn(fmt.Sprintf("\t[%q] skipping %q, file referenced by SHASUMS not found in release assets: %q", p, r.TagName, sum.File))
+					err = cmd.invalidRelease(p.OnInvalidRelease, logEvent{Provider: p.String(), Version: r.TagName, Reason: fmt.Sprintf("file referenced by SHASUMS not found in release assets: %q", sum.File)})
+					if err != nil {
+						return err
+					}
 					goto NextRelease
 				}
 				name := sum.File
 				name = strings.TrimSuffix(name, path.Ext(name))
 				nameParts := strings.Split(name, "_")
 				if len(nameParts) != 4 {
-					cmd.ui.Warn(fmt.Sprintf("\t[%q] skipping %q, malformed asset file: %q", p, r.TagName, ra.Name))
+					err = cmd.invalidRelease(p.OnInvalidRelease, logEvent{Provider: p.String(), Version: r.TagName, Reason: fmt.Sprintf("malformed asset file: %q", ra.Name)})
+					if err != nil {
+						return err
+					}
 					goto NextRelease
 				}
 				os, arch := nameParts[2], nameParts[3]
@@ -199,15 +235,44 @@ func (cmd *generateCmd) collectGitHubProvider(ctx context.Context, p provider, r
 				}
 			}
 
+			for _, plat := range platforms {
+				cmd.log(logEvent{
+					Level:    "debug",
+					Action:   "collect_platform",
+					Provider: p.String(),
+					Version:  ver,
+					Platform: plat.OS + "_" + plat.Arch,
+				})
+			}
+
 			versionsIndex.Versions = append(versionsIndex.Versions, providerVersion{
 				Version:   ver,
 				Platforms: platforms,
 
 				Protocols: providerProtocols,
 			})
+			vd = providerVersionDetails{
+				Tag:         r.TagName,
+				PublishedAt: r.PublishedAt.Time,
+			}
+			if cmd.docs {
+				cmd.ui.Info(fmt.Sprintf("\t[%q] fetching docs for %q...", p, r.TagName))
+				vd.Docs, err = cmd.collectGitHubDocs(ctx, owner, name, r.TagName, providerDownloadKey{
+					Namespace: p.Namespace,
+					Name:      p.Name,
+					Version:   ver,
+				})
+				if err != nil {
+					return fmt.Errorf("unable to fetch docs for %q: %w", r.TagName, err)
+				}
+			}
+			details.Versions[ver] = vd
 		NextRelease:
 		}
 
+		details.Description = q.Repository.Description
+		details.Source = q.Repository.URL
+
 		if !q.Repository.Releases.PageInfo.HasNextPage {
 			break
 		}
@@ -219,6 +284,14 @@ func (cmd *generateCmd) collectGitHubProvider(ctx context.Context, p provider, r
 		Name:      p.Name,
 	}] = versionsIndex
 
+	if p.Description != "" {
+		details.Description = p.Description
+	}
+	rd.ProviderDetails[providerVersionsKey{
+		Namespace: p.Namespace,
+		Name:      p.Name,
+	}] = details
+
 	// TODO: ui done?
 
 	return nil
@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// GitHub Pages has no rewrites, so the registry is written with the exact
+// layout. Terraform only checks the content type of the service discovery
+// document, which keeps its .json extension.
+
+func (cmd *generateCmd) generateGitHubPages(ctx context.Context, rd registryData, srv server) error {
+	err := cmd.generateSite(ctx, rd, exactLayout, srv)
+	if err != nil {
+		return err
+	}
+
+	// without this Jekyll ignores the .well-known directory
+	cmd.ui.Info("\t[github-pages] writing .nojekyll file...")
+	err = cmd.writeFile(filepath.Join(srv.Output, ".nojekyll"), nil)
+	if err != nil {
+		return fmt.Errorf("unable to write .nojekyll file: %w", err)
+	}
+
+	if srv.CNAME != "" {
+		cmd.ui.Info("\t[github-pages] writing CNAME file...")
+		err = cmd.writeFile(filepath.Join(srv.Output, "CNAME"), []byte(srv.CNAME+"\n"))
+		if err != nil {
+			return fmt.Errorf("unable to write CNAME file: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (cmd *generateCmd) publishGitHubPages(ctx context.Context, srv server) error {
+	if srv.Push == "" {
+		return nil
+	}
+
+	cmd.ui.Info(fmt.Sprintf("\t[github-pages] pushing to %q...", srv.Push))
+	return pushGitHubPages(ctx, srv.Output, srv.Push)
+}
+
+// pushGitHubPages force pushes the contents of dir as a single commit to the
+// gh-pages branch of remote. The repository is kept outside of dir so it is
+// never part of the generated site.
+func pushGitHubPages(ctx context.Context, dir, remote string) error {
+	gitDir, err := ioutil.TempDir("", "tfstaticregistry-git")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(gitDir)
+
+	git := func(args ...string) (string, error) {
+		args = append([]string{"--git-dir", gitDir, "--work-tree", dir}, args...)
+		c := exec.CommandContext(ctx, "git", args...)
+		var out bytes.Buffer
+		c.Stdout = &out
+		c.Stderr = &out
+		err := c.Run()
+		if err != nil {
+			return "", fmt.Errorf("git %s: %w: %s", strings.Join(args[4:], " "), err, out.String())
+		}
+		return strings.TrimSpace(out.String()), nil
+	}
+
+	if _, err := git("init", "--quiet"); err != nil {
+		return err
+	}
+	if _, err := git("checkout", "--quiet", "--orphan", "gh-pages"); err != nil {
+		return err
+	}
+	err = ioutil.WriteFile(filepath.Join(gitDir, "info", "exclude"), []byte("/"+manifestFile+"\n"), 0644)
+	if err != nil {
+		return fmt.Errorf("unable to write git excludes: %w", err)
+	}
+	if _, err := git("add", "--all"); err != nil {
+		return err
+	}
+
+	commit := []string{"commit", "--quiet", "--message", "Update Terraform registry"}
+	if name, _ := git("config", "user.name"); name == "" {
+		commit = append([]string{"-c", "user.name=tfstaticregistry", "-c", "user.email=tfstaticregistry@localhost"}, commit...)
+	}
+	if _, err := git(commit...); err != nil {
+		return err
+	}
+
+	if _, err := git("push", "--quiet", "--force", remote, "gh-pages"); err != nil {
+		return err
+	}
+	return nil
+}
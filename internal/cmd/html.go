@@ -0,0 +1,231 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const htmlIndexTemplate = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Terraform Registry</title>
+</head>
+<body>
+<h1>Terraform Registry</h1>
+<h2>Providers</h2>
+<table>
+<thead><tr><th>Provider</th><th>Latest</th><th>Versions</th><th>Description</th></tr></thead>
+<tbody>
+{{- range .Providers }}
+<tr>
+<td><a href="{{ .Page }}">{{ .Namespace }}/{{ .Name }}</a></td>
+<td>{{ .Latest }}</td>
+<td>{{ len .Versions }}</td>
+<td>{{ .Description }}</td>
+</tr>
+{{- end }}
+</tbody>
+</table>
+</body>
+</html>
+`
+
+const htmlProviderTemplate = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{ .Namespace }}/{{ .Name }}</title>
+</head>
+<body>
+<nav><a href="../../index.html">Terraform Registry</a></nav>
+<h1>{{ .Namespace }}/{{ .Name }}</h1>
+{{- with .Description }}
+<p>{{ . }}</p>
+{{- end }}
+{{- with .Source }}
+<p><a href="{{ . }}">{{ . }}</a></p>
+{{- end }}
+<h2>Usage</h2>
+<pre><code>terraform {
+  required_providers {
+    {{ .Name }} = {
+      source  = "{{ .Hostname }}/{{ .Namespace }}/{{ .Name }}"
+      version = "{{ .Latest }}"
+    }
+  }
+}</code></pre>
+<h2>Versions</h2>
+<table>
+<thead><tr><th>Version</th><th>Platforms</th><th>Signing Keys</th></tr></thead>
+<tbody>
+{{- range .Versions }}
+<tr>
+<td>{{ .Version }}</td>
+<td>{{ range $i, $p := .Platforms }}{{ if $i }}, {{ end }}{{ $p.OS }}_{{ $p.Arch }}{{ end }}</td>
+<td>{{ range $i, $k := .KeyIDs }}{{ if $i }}, {{ end }}{{ $k }}{{ end }}</td>
+</tr>
+{{- end }}
+</tbody>
+</table>
+</body>
+</html>
+`
+
+type htmlIndex struct {
+	Hostname  string
+	Providers []htmlProvider
+}
+
+type htmlProvider struct {
+	Hostname    string
+	Namespace   string
+	Name        string
+	Description string
+	Source      string
+	Latest      string
+	Page        string
+	Versions    []htmlProviderVersion
+}
+
+type htmlProviderVersion struct {
+	Version   string
+	Platforms []platform
+	KeyIDs    []string
+}
+
+// loadHTMLTemplates parses the default templates, replacing any that are
+// present in the template directory.
+func loadHTMLTemplates(dir string) (*template.Template, error) {
+	defaults := map[string]string{
+		"index.html":    htmlIndexTemplate,
+		"provider.html": htmlProviderTemplate,
+	}
+
+	tmpl := template.New("")
+	for name, text := range defaults {
+		if dir != "" {
+			file := filepath.Join(dir, name)
+			if b, err := ioutil.ReadFile(file); err == nil {
+				text = string(b)
+			} else if !os.IsNotExist(err) {
+				return nil, fmt.Errorf("unable to read template %q: %w", file, err)
+			}
+		}
+
+		_, err := tmpl.New(name).Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse template %q: %w", name, err)
+		}
+	}
+	return tmpl, nil
+}
+
+func (cmd *generateCmd) generateHTML(ctx context.Context, rd registryData, srv server) error {
+	outputDir := srv.registryDir()
+	tmpl, err := loadHTMLTemplates(cmd.templateDir)
+	if err != nil {
+		return err
+	}
+
+	hostname := cmd.hostname
+	if hostname == "" && srv.BaseURL != "" {
+		if u, err := url.Parse(srv.BaseURL); err == nil {
+			hostname = u.Host
+		}
+	}
+	if hostname == "" {
+		hostname = "HOSTNAME"
+		cmd.ui.Warn("\t\tno hostname or base URL set, using a placeholder in the provider usage snippets")
+	}
+
+	index := htmlIndex{
+		Hostname: hostname,
+	}
+
+	for k, vi := range rd.ProviderVersions {
+		details := rd.ProviderDetails[k]
+
+		hp := htmlProvider{
+			Hostname:    hostname,
+			Namespace:   k.Namespace,
+			Name:        k.Name,
+			Description: details.Description,
+			Source:      details.Source,
+			Page:        fmt.Sprintf("providers/%s/%s.html", strings.ToLower(k.Namespace), strings.ToLower(k.Name)),
+		}
+
+		versions := sortedVersions(vi)
+		if latest := latestVersion(versions); latest != nil {
+			hp.Latest = latest.Original()
+		}
+
+		platforms := map[string][]platform{}
+		for _, pv := range vi.Versions {
+			platforms[pv.Version] = pv.Platforms
+		}
+
+		// newest first
+		for i := len(versions) - 1; i >= 0; i-- {
+			ver := versions[i].Original()
+			hv := htmlProviderVersion{
+				Version:   ver,
+				Platforms: platforms[ver],
+			}
+
+			keyIDs := map[string]bool{}
+			for _, plat := range hv.Platforms {
+				d := rd.Downloads[providerDownloadKey{
+					Namespace: k.Namespace,
+					Name:      k.Name,
+					Version:   ver,
+					OS:        plat.OS,
+					Arch:      plat.Arch,
+				}]
+				for _, key := range d.SigningKeys.GPGPublicKeys {
+					if !keyIDs[key.KeyID] {
+						keyIDs[key.KeyID] = true
+						hv.KeyIDs = append(hv.KeyIDs, key.KeyID)
+					}
+				}
+			}
+
+			hp.Versions = append(hp.Versions, hv)
+		}
+
+		var page bytes.Buffer
+		err = tmpl.ExecuteTemplate(&page, "provider.html", hp)
+		if err != nil {
+			return fmt.Errorf("unable to render provider page for %q: %w", vi.ID, err)
+		}
+		err = cmd.writeFile(filepath.Join(outputDir, filepath.FromSlash(hp.Page)), page.Bytes())
+		if err != nil {
+			return err
+		}
+
+		index.Providers = append(index.Providers, hp)
+	}
+
+	sort.Slice(index.Providers, func(i, j int) bool {
+		a, b := index.Providers[i], index.Providers[j]
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name < b.Name
+	})
+
+	var page bytes.Buffer
+	err = tmpl.ExecuteTemplate(&page, "index.html", index)
+	if err != nil {
+		return fmt.Errorf("unable to render index page: %w", err)
+	}
+	return cmd.writeFile(filepath.Join(outputDir, "index.html"), page.Bytes())
+}
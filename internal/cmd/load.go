@@ -0,0 +1,477 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/gohcl"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+	"gopkg.in/yaml.v3"
+)
+
+// varFlags collects the repeatable -var name=value flags.
+type varFlags []string
+
+func (v *varFlags) String() string {
+	return strings.Join(*v, ",")
+}
+
+func (v *varFlags) Set(value string) error {
+	if !strings.Contains(value, "=") {
+		return fmt.Errorf("variable %q must be of the form name=value", value)
+	}
+	*v = append(*v, value)
+	return nil
+}
+
+func (cmd *commonCmd) configFlags(fs *flag.FlagSet) {
+	fs.StringVar(&cmd.configPath, "config", "", "configuration file or directory, defaults to registry.hcl, registry.hcl.json or registry.yaml")
+	fs.Var(&cmd.vars, "var", "set a configuration variable as name=value, can be repeated")
+}
+
+// loadConfig decodes and validates the configuration, diagnostics are
+// written to the UI.
+func (cmd *commonCmd) loadConfig() (config, error) {
+	if cmd.configPath == "" {
+		cmd.configPath = defaultConfigFile()
+	}
+
+	parser := hclparse.NewParser()
+	conf, diags := decodeConfig(parser, cmd.configPath, cmd.vars)
+
+	if len(diags) > 0 {
+		var b strings.Builder
+		wr := hcl.NewDiagnosticTextWriter(&b, parser.Files(), 78, false)
+		err := wr.WriteDiagnostics(diags)
+		if err != nil {
+			return config{}, err
+		}
+		if diags.HasErrors() {
+			cmd.ui.Error(b.String())
+		} else {
+			cmd.ui.Warn(b.String())
+		}
+	}
+
+	if diags.HasErrors() {
+		return config{}, configError(fmt.Errorf("%s is not valid", cmd.configPath))
+	}
+	return conf, nil
+}
+
+// defaultConfigFile returns the first configuration file that exists in the
+// working directory.
+func defaultConfigFile() string {
+	for _, f := range configFiles {
+		if _, err := os.Stat(f); err == nil {
+			return f
+		}
+	}
+	return configFile
+}
+
+// configFiles are the default configuration files, in order of precedence.
+var configFiles = []string{configFile, configFile + ".json", "registry.yaml", "registry.yml"}
+
+// configDirPatterns are the files read from a configuration directory, YAML
+// files are named like the default registry.yaml.
+var configDirPatterns = []string{"*.hcl", "*.hcl.json", "*.yaml", "*.yml"}
+
+// listConfigFiles returns the file, or the configuration files of the
+// directory.
+func listConfigFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read configuration: %w", err)
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	files := []string{}
+	for _, pattern := range configDirPatterns {
+		matches, err := filepath.Glob(filepath.Join(path, pattern))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, matches...)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no configuration files found in %q", path)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// parseConfigFile parses native HCL, HCL JSON or YAML by the file extension.
+// YAML is converted to HCL JSON, so it follows the same structure.
+func parseConfigFile(parser *hclparse.Parser, file string) (*hcl.File, hcl.Diagnostics) {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".json":
+		return parser.ParseJSONFile(file)
+	case ".yaml", ".yml":
+		src, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, hcl.Diagnostics{{
+				Severity: hcl.DiagError,
+				Summary:  "Failed to read file",
+				Detail:   fmt.Sprintf("The configuration file %q could not be read.", file),
+			}}
+		}
+		body, err := yamlToJSON(src)
+		if err != nil {
+			return nil, hcl.Diagnostics{{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid YAML",
+				Detail:   fmt.Sprintf("Unable to parse %q: %s.", file, err),
+			}}
+		}
+		return parser.ParseJSON(body, file)
+	default:
+		return parser.ParseHCLFile(file)
+	}
+}
+
+// yamlToJSON converts a YAML document to JSON, keeping every value on the
+// line it was on so diagnostics refer to the YAML lines.
+func yamlToJSON(src []byte) ([]byte, error) {
+	var doc yaml.Node
+	err := yaml.Unmarshal(src, &doc)
+	if err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return []byte("{}"), nil
+	}
+
+	w := &yamlJSONWriter{line: 1}
+	err = w.writeNode(doc.Content[0])
+	if err != nil {
+		return nil, err
+	}
+	return w.buf.Bytes(), nil
+}
+
+type yamlJSONWriter struct {
+	buf  bytes.Buffer
+	line int
+}
+
+func (w *yamlJSONWriter) moveTo(line int) {
+	for ; w.line < line; w.line++ {
+		w.buf.WriteByte('\n')
+	}
+}
+
+func (w *yamlJSONWriter) writeNode(n *yaml.Node) error {
+	w.moveTo(n.Line)
+
+	switch n.Kind {
+	case yaml.AliasNode:
+		return w.writeNode(n.Alias)
+	case yaml.MappingNode:
+		w.buf.WriteByte('{')
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if i > 0 {
+				w.buf.WriteByte(',')
+			}
+			k := n.Content[i]
+			w.moveTo(k.Line)
+			key, err := json.Marshal(k.Value)
+			if err != nil {
+				return err
+			}
+			w.buf.Write(key)
+			w.buf.WriteByte(':')
+			err = w.writeNode(n.Content[i+1])
+			if err != nil {
+				return err
+			}
+		}
+		w.buf.WriteByte('}')
+	case yaml.SequenceNode:
+		w.buf.WriteByte('[')
+		for i, item := range n.Content {
+			if i > 0 {
+				w.buf.WriteByte(',')
+			}
+			err := w.writeNode(item)
+			if err != nil {
+				return err
+			}
+		}
+		w.buf.WriteByte(']')
+	case yaml.ScalarNode:
+		var v interface{} = n.Value
+		if n.ShortTag() != "!!str" {
+			err := n.Decode(&v)
+			if err != nil {
+				return err
+			}
+		}
+		value, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", n.Line, err)
+		}
+		w.buf.Write(value)
+	default:
+		return fmt.Errorf("line %d: unsupported YAML node", n.Line)
+	}
+	return nil
+}
+
+var variablesSchema = &hcl.BodySchema{
+	Blocks: []hcl.BlockHeaderSchema{
+		{Type: "variable", LabelNames: []string{"name"}},
+		{Type: "locals"},
+	},
+}
+
+type variable struct {
+	Name        string         `hcl:"name,label"`
+	Default     hcl.Expression `hcl:"default,optional"`
+	Description string         `hcl:"description,optional"`
+}
+
+func decodeConfig(parser *hclparse.Parser, path string, vars []string) (config, hcl.Diagnostics) {
+	var conf config
+
+	files, err := listConfigFiles(path)
+	if err != nil {
+		return conf, hcl.Diagnostics{{
+			Severity: hcl.DiagError,
+			Summary:  "Unable to read configuration",
+			Detail:   capitalize(err.Error()) + ".",
+		}}
+	}
+
+	var diags hcl.Diagnostics
+	parsed := []*hcl.File{}
+	for _, f := range files {
+		file, fileDiags := parseConfigFile(parser, f)
+		diags = append(diags, fileDiags...)
+		parsed = append(parsed, file)
+	}
+	if diags.HasErrors() {
+		return conf, diags
+	}
+
+	content, body, contentDiags := hcl.MergeFiles(parsed).PartialContent(variablesSchema)
+	diags = append(diags, contentDiags...)
+	if diags.HasErrors() {
+		return conf, diags
+	}
+
+	ctx := &hcl.EvalContext{
+		Functions: configFunctions,
+	}
+
+	varValues, varDiags := evalVariables(ctx, content.Blocks.OfType("variable"), vars)
+	diags = append(diags, varDiags...)
+	if diags.HasErrors() {
+		return conf, diags
+	}
+	ctx.Variables = map[string]cty.Value{
+		"var": cty.ObjectVal(varValues),
+	}
+
+	localValues, localDiags := evalLocals(ctx, content.Blocks.OfType("locals"))
+	diags = append(diags, localDiags...)
+	if diags.HasErrors() {
+		return conf, diags
+	}
+	ctx.Variables["local"] = cty.ObjectVal(localValues)
+
+	diags = append(diags, gohcl.DecodeBody(body, ctx, &conf)...)
+	if diags.HasErrors() {
+		return conf, diags
+	}
+
+	diags = append(diags, validateConfig(body, conf)...)
+	return conf, diags
+}
+
+// evalVariables returns the variable values, -var flags take precedence over
+// the defaults.
+func evalVariables(ctx *hcl.EvalContext, blocks hcl.Blocks, vars []string) (map[string]cty.Value, hcl.Diagnostics) {
+	var diags hcl.Diagnostics
+
+	flagValues := map[string]string{}
+	for _, v := range vars {
+		parts := strings.SplitN(v, "=", 2)
+		flagValues[parts[0]] = parts[1]
+	}
+
+	values := map[string]cty.Value{}
+	declared := map[string]*hcl.Block{}
+	for _, b := range blocks {
+		var v variable
+		blockDiags := gohcl.DecodeBody(b.Body, nil, &v)
+		diags = append(diags, blockDiags...)
+		if blockDiags.HasErrors() {
+			continue
+		}
+		v.Name = b.Labels[0]
+
+		if prev, ok := declared[v.Name]; ok {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Duplicate variable block",
+				Detail:   fmt.Sprintf("Variable %q was already declared at %s.", v.Name, prev.DefRange),
+				Subject:  b.DefRange.Ptr(),
+			})
+			continue
+		}
+		declared[v.Name] = b
+
+		if fv, ok := flagValues[v.Name]; ok {
+			values[v.Name] = cty.StringVal(fv)
+			continue
+		}
+
+		val, valDiags := v.Default.Value(ctx)
+		diags = append(diags, valDiags...)
+		if val.IsNull() {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Missing variable value",
+				Detail:   fmt.Sprintf("Variable %q has no default, a value must be set with -var %s=VALUE.", v.Name, v.Name),
+				Subject:  b.DefRange.Ptr(),
+			})
+			continue
+		}
+		values[v.Name] = val
+	}
+
+	for name := range flagValues {
+		if _, ok := declared[name]; !ok {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Undeclared variable",
+				Detail:   fmt.Sprintf("A value was set for variable %q with -var, but it is not declared in a variable block.", name),
+			})
+		}
+	}
+
+	return values, diags
+}
+
+// evalLocals evaluates the locals in dependency order, locals can refer to
+// variables and other locals.
+func evalLocals(ctx *hcl.EvalContext, blocks hcl.Blocks) (map[string]cty.Value, hcl.Diagnostics) {
+	var diags hcl.Diagnostics
+
+	pending := map[string]*hcl.Attribute{}
+	for _, b := range blocks {
+		attrs, attrDiags := b.Body.JustAttributes()
+		diags = append(diags, attrDiags...)
+		for name, attr := range attrs {
+			if prev, ok := pending[name]; ok {
+				diags = append(diags, &hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Duplicate local value",
+					Detail:   fmt.Sprintf("Local %q was already defined at %s.", name, prev.NameRange),
+					Subject:  attr.NameRange.Ptr(),
+				})
+				continue
+			}
+			pending[name] = attr
+		}
+	}
+
+	values := map[string]cty.Value{}
+	for len(pending) > 0 {
+		progress := false
+		for name, attr := range pending {
+			if !localsResolved(attr.Expr, values, pending) {
+				continue
+			}
+
+			localCtx := ctx.NewChild()
+			localCtx.Variables = map[string]cty.Value{
+				"local": cty.ObjectVal(values),
+			}
+			val, valDiags := attr.Expr.Value(localCtx)
+			diags = append(diags, valDiags...)
+			values[name] = val
+			delete(pending, name)
+			progress = true
+		}
+
+		if !progress {
+			names := make([]string, 0, len(pending))
+			for name := range pending {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				attr := pending[name]
+				diags = append(diags, &hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Unresolvable local value",
+					Detail:   fmt.Sprintf("Local %q refers to itself, directly or through other locals.", name),
+					Subject:  attr.Expr.Range().Ptr(),
+				})
+			}
+			break
+		}
+	}
+
+	return values, diags
+}
+
+// localsResolved reports whether every local the expression refers to has a
+// value, references to undefined locals are reported when evaluating.
+func localsResolved(expr hcl.Expression, values map[string]cty.Value, pending map[string]*hcl.Attribute) bool {
+	for _, t := range expr.Variables() {
+		if t.RootName() != "local" || len(t) < 2 {
+			continue
+		}
+		attr, ok := t[1].(hcl.TraverseAttr)
+		if !ok {
+			continue
+		}
+		if _, ok := values[attr.Name]; ok {
+			continue
+		}
+		if _, ok := pending[attr.Name]; ok {
+			return false
+		}
+	}
+	return true
+}
+
+var configFunctions = map[string]function.Function{
+	"env": function.New(&function.Spec{
+		Params: []function.Parameter{
+			{Name: "name", Type: cty.String},
+		},
+		Type: function.StaticReturnType(cty.String),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			return cty.StringVal(os.Getenv(args[0].AsString())), nil
+		},
+	}),
+	"file": function.New(&function.Spec{
+		Params: []function.Parameter{
+			{Name: "path", Type: cty.String},
+		},
+		Type: function.StaticReturnType(cty.String),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			body, err := ioutil.ReadFile(args[0].AsString())
+			if err != nil {
+				return cty.NilVal, err
+			}
+			return cty.StringVal(string(body)), nil
+		},
+	}),
+}
@@ -0,0 +1,244 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/cli"
+)
+
+// logEvent is a structured progress event, written as a JSON line with
+// -log-format json, otherwise only the message is shown.
+type logEvent struct {
+	Time     time.Time `json:"time"`
+	Level    string    `json:"level"`
+	Action   string    `json:"action,omitempty"`
+	Server   string    `json:"server,omitempty"`
+	Provider string    `json:"provider,omitempty"`
+	Module   string    `json:"module,omitempty"`
+	Version  string    `json:"version,omitempty"`
+	Platform string    `json:"platform,omitempty"`
+	File     string    `json:"file,omitempty"`
+	Reason   string    `json:"reason,omitempty"`
+	Message  string    `json:"message,omitempty"`
+}
+
+// jsonUi writes every message as a JSON event line to the output.
+type jsonUi struct {
+	cli.Ui
+}
+
+func (u *jsonUi) Output(message string) { u.emit(logEvent{Level: "info", Message: message}) }
+func (u *jsonUi) Info(message string)   { u.emit(logEvent{Level: "info", Message: message}) }
+func (u *jsonUi) Warn(message string)   { u.emit(logEvent{Level: "warn", Message: message}) }
+func (u *jsonUi) Error(message string)  { u.emit(logEvent{Level: "error", Message: message}) }
+
+func (u *jsonUi) emit(e logEvent) {
+	e.Message = strings.TrimSpace(e.Message)
+	if e.Message == "" && e.Action == "" {
+		return
+	}
+	e.Time = time.Now().UTC()
+
+	line, err := json.Marshal(e)
+	if err != nil {
+		// should never happen, the event only has strings
+		panic(err)
+	}
+	u.Ui.Output(string(line))
+}
+
+// log shows a progress event, debug events are only written as JSON.
+func (cmd *generateCmd) log(e logEvent) {
+	if u, ok := cmd.ui.(*jsonUi); ok {
+		u.emit(e)
+		return
+	}
+
+	switch e.Level {
+	case "debug":
+	case "warn":
+		cmd.ui.Warn(e.Message)
+	default:
+		cmd.ui.Info(e.Message)
+	}
+}
+
+// skipRelease logs a release that is not collected and records it in the
+// report, the event needs a provider or module, the version and a reason.
+func (cmd *generateCmd) skipRelease(e logEvent) {
+	name := e.Provider
+	if name == "" {
+		name = e.Module
+	}
+	if e.Level == "" {
+		e.Level = "warn"
+	}
+	e.Action = "skip"
+	e.Message = fmt.Sprintf("\t[%q] skipping %q, %s", name, e.Version, e.Reason)
+
+	cmd.report.Skipped = append(cmd.report.Skipped, reportSkip{
+		Provider: e.Provider,
+		Module:   e.Module,
+		Version:  e.Version,
+		Reason:   e.Reason,
+	})
+	cmd.log(e)
+}
+
+// invalidRelease handles a release that can not be collected, mode is the
+// on_invalid_release setting of the source. An error is returned to fail the
+// run, otherwise the release is skipped.
+func (cmd *generateCmd) invalidRelease(mode string, e logEvent) error {
+	if mode == "" {
+		mode = "warn"
+		if cmd.strict {
+			mode = "error"
+		}
+	}
+
+	switch mode {
+	case "error":
+		return invalidReleaseError(fmt.Errorf("invalid release %q: %s", e.Version, e.Reason))
+	case "ignore":
+		e.Level = "debug"
+	}
+	cmd.skipRelease(e)
+	return nil
+}
+
+// report summarizes a generate run for CI.
+type report struct {
+	Providers []reportSource `json:"providers"`
+	Modules   []reportSource `json:"modules"`
+	Skipped   []reportSkip   `json:"skipped"`
+	Servers   []reportServer `json:"servers"`
+	Error     string         `json:"error,omitempty"`
+}
+
+type reportSource struct {
+	Name     string   `json:"name"`
+	Versions []string `json:"versions"`
+}
+
+type reportSkip struct {
+	Provider string `json:"provider,omitempty"`
+	Module   string `json:"module,omitempty"`
+	Version  string `json:"version"`
+	Reason   string `json:"reason"`
+}
+
+type reportServer struct {
+	Type   string   `json:"type"`
+	Output string   `json:"output"`
+	Format string   `json:"format"`
+	Files  []string `json:"files"`
+	Stale  []string `json:"stale"`
+}
+
+func newReport() report {
+	return report{
+		Providers: []reportSource{},
+		Modules:   []reportSource{},
+		Skipped:   []reportSkip{},
+		Servers:   []reportServer{},
+	}
+}
+
+// addRegistryData records the collected provider and module versions.
+func (r *report) addRegistryData(rd registryData) {
+	for k, v := range rd.ProviderVersions {
+		versions := []string{}
+		for _, pv := range v.Versions {
+			versions = append(versions, pv.Version)
+		}
+		sort.Strings(versions)
+		r.Providers = append(r.Providers, reportSource{
+			Name:     k.Namespace + "/" + k.Name,
+			Versions: versions,
+		})
+	}
+	sort.Slice(r.Providers, func(i, j int) bool {
+		return r.Providers[i].Name < r.Providers[j].Name
+	})
+
+	for k, v := range rd.ModuleVersions {
+		versions := []string{}
+		for _, m := range v.Modules {
+			for _, mv := range m.Versions {
+				versions = append(versions, mv.Version)
+			}
+		}
+		sort.Strings(versions)
+		r.Modules = append(r.Modules, reportSource{
+			Name:     k.Namespace + "/" + k.Name + "/" + k.System,
+			Versions: versions,
+		})
+	}
+	sort.Slice(r.Modules, func(i, j int) bool {
+		return r.Modules[i].Name < r.Modules[j].Name
+	})
+}
+
+// addServer records the files written for a server, relative to its output
+// directory.
+func (r *report) addServer(srv server, written map[string]bool, stale []string) error {
+	rs := reportServer{
+		Type:   srv.Type,
+		Output: srv.targetDir(),
+		Format: srv.Format,
+		Files:  []string{},
+		Stale:  []string{},
+	}
+	rs.Stale = append(rs.Stale, stale...)
+	for f := range written {
+		rel, err := filepath.Rel(srv.Output, f)
+		if err != nil {
+			return err
+		}
+		rs.Files = append(rs.Files, filepath.ToSlash(rel))
+	}
+	sort.Strings(rs.Files)
+	r.Servers = append(r.Servers, rs)
+	return nil
+}
+
+// addRemoteStale records the stale objects of the storage service of the
+// last server added.
+func (r *report) addRemoteStale(stale []string) {
+	if len(r.Servers) == 0 {
+		return
+	}
+	rs := &r.Servers[len(r.Servers)-1]
+	seen := map[string]bool{}
+	for _, f := range rs.Stale {
+		seen[f] = true
+	}
+	for _, f := range stale {
+		if !seen[f] {
+			rs.Stale = append(rs.Stale, f)
+		}
+	}
+	sort.Strings(rs.Stale)
+}
+
+func (cmd *generateCmd) writeReport(runErr error) error {
+	if runErr != nil {
+		cmd.report.Error = runErr.Error()
+	}
+
+	body, err := json.MarshalIndent(cmd.report, "", "\t")
+	if err != nil {
+		return err
+	}
+	err = ioutil.WriteFile(cmd.reportFile, body, 0644)
+	if err != nil {
+		return fmt.Errorf("unable to write report %q: %w", cmd.reportFile, err)
+	}
+	return nil
+}
@@ -57,9 +57,47 @@ func initCommands(ui cli.Ui) map[string]cli.CommandFactory {
 		}, nil
 	}
 
+	serveFactory := func() (cli.Command, error) {
+		return &serveCmd{
+			commonCmd: commonCmd{
+				ui: ui,
+			},
+		}, nil
+	}
+
+	checkFactory := func() (cli.Command, error) {
+		return &checkCmd{
+			commonCmd: commonCmd{
+				ui: ui,
+			},
+		}, nil
+	}
+
+	planFactory := func() (cli.Command, error) {
+		return &planCmd{
+			generateCmd: generateCmd{
+				commonCmd: commonCmd{
+					ui: ui,
+				},
+			},
+		}, nil
+	}
+
+	validateFactory := func() (cli.Command, error) {
+		return &validateCmd{
+			commonCmd: commonCmd{
+				ui: ui,
+			},
+		}, nil
+	}
+
 	return map[string]cli.CommandFactory{
 		"":         defaultFactory,
+		"check":    checkFactory,
 		"generate": generateFactory,
+		"plan":     planFactory,
+		"serve":    serveFactory,
+		"validate": validateFactory,
 	}
 }
 
@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/go-version"
+	"github.com/shurcooL/githubv4"
+)
+
+// see https://www.terraform.io/docs/internals/module-registry-protocol.html
+
+type moduleVersionsKey struct {
+	Namespace string
+	Name      string
+	System    string
+}
+
+type moduleVersionsIndex struct {
+	Modules []moduleVersions `json:"modules"`
+}
+
+type moduleVersions struct {
+	Versions []moduleVersion `json:"versions"`
+}
+
+type moduleVersion struct {
+	Version string `json:"version"`
+}
+
+type moduleDownloadKey struct {
+	Namespace string
+	Name      string
+	System    string
+	Version   string
+}
+
+// moduleDownload is the body of the download response. Terraform reads the
+// X-Terraform-Get header first, and from 0.13.2 falls back to the location in
+// the body when the header is not set.
+type moduleDownload struct {
+	Location string `json:"location"`
+}
+
+func (cmd *generateCmd) collectGitHubModule(ctx context.Context, m module, rd registryData) error {
+	cmd.ui.Info(fmt.Sprintf("\t[%q] collecting GitHub information...", m))
+
+	if cmd.githubClient == nil {
+		return authError(fmt.Errorf("no GitHub client configured, please specify api token"))
+	}
+
+	repoParts := strings.Split(m.GitHub.Repository, "/")
+	if len(repoParts) != 2 {
+		return fmt.Errorf("malformed github repository %q", m.GitHub.Repository)
+	}
+	owner, name := repoParts[0], repoParts[1]
+
+	var q struct {
+		Repository struct {
+			Refs struct {
+				PageInfo struct {
+					EndCursor   githubv4.String
+					HasNextPage bool
+				}
+				Nodes []struct {
+					Name string
+				}
+			} `graphql:"refs(refPrefix: \"refs/tags/\", first: 100, after: $tagsCursor)"`
+		} `graphql:"repository(owner: $owner, name: $name)"`
+	}
+	variables := map[string]interface{}{
+		"owner":      githubv4.String(owner),
+		"name":       githubv4.String(name),
+		"tagsCursor": (*githubv4.String)(nil),
+	}
+
+	versions := moduleVersions{}
+
+	for {
+		err := cmd.githubClient.Query(ctx, &q, variables)
+		if err != nil {
+			return githubError(err)
+		}
+
+		for _, tag := range q.Repository.Refs.Nodes {
+			ver := strings.TrimPrefix(tag.Name, "v")
+			if _, err := version.NewSemver(ver); err != nil {
+				err = cmd.invalidRelease(m.OnInvalidRelease, logEvent{Module: m.String(), Version: tag.Name, Reason: fmt.Sprintf("not valid semver: %s", err)})
+				if err != nil {
+					return err
+				}
+				continue
+			}
+
+			cmd.log(logEvent{
+				Level:   "info",
+				Action:  "collect",
+				Module:  m.String(),
+				Version: tag.Name,
+				Message: fmt.Sprintf("\t[%q] processing tag %q...", m, tag.Name),
+			})
+
+			location := fmt.Sprintf("git::https://github.com/%s/%s?ref=%s", owner, name, tag.Name)
+			if m.GitHub.Archive {
+				// GitHub tarballs nest the contents in a single directory named after the repository and tag
+				location = fmt.Sprintf("https://github.com/%s/%s/archive/%s.tar.gz//*", owner, name, tag.Name)
+			}
+
+			versions.Versions = append(versions.Versions, moduleVersion{
+				Version: ver,
+			})
+			rd.ModuleDownloads[moduleDownloadKey{
+				Namespace: m.Namespace,
+				Name:      m.Name,
+				System:    m.System,
+				Version:   ver,
+			}] = moduleDownload{
+				Location: location,
+			}
+		}
+
+		if !q.Repository.Refs.PageInfo.HasNextPage {
+			break
+		}
+		variables["tagsCursor"] = githubv4.NewString(q.Repository.Refs.PageInfo.EndCursor)
+	}
+
+	rd.ModuleVersions[moduleVersionsKey{
+		Namespace: m.Namespace,
+		Name:      m.Name,
+		System:    m.System,
+	}] = moduleVersionsIndex{
+		Modules: []moduleVersions{versions},
+	}
+
+	return nil
+}
+
+func (cmd *generateCmd) collectArchiveModule(ctx context.Context, m module, rd registryData) error {
+	cmd.ui.Info(fmt.Sprintf("\t[%q] collecting archives...", m))
+
+	versions := moduleVersions{}
+
+	for _, a := range m.Archives {
+		if _, err := version.NewSemver(a.Version); err != nil {
+			return fmt.Errorf("archive version %q is not valid semver: %w", a.Version, err)
+		}
+
+		versions.Versions = append(versions.Versions, moduleVersion{
+			Version: a.Version,
+		})
+		rd.ModuleDownloads[moduleDownloadKey{
+			Namespace: m.Namespace,
+			Name:      m.Name,
+			System:    m.System,
+			Version:   a.Version,
+		}] = moduleDownload{
+			Location: a.URL,
+		}
+	}
+
+	rd.ModuleVersions[moduleVersionsKey{
+		Namespace: m.Namespace,
+		Name:      m.Name,
+		System:    m.System,
+	}] = moduleVersionsIndex{
+		Modules: []moduleVersions{versions},
+	}
+
+	return nil
+}
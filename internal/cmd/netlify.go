@@ -2,78 +2,151 @@ package cmd
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+
+	"github.com/BurntSushi/toml"
 )
 
-func (cmd *generateCmd) generateNetlify(ctx context.Context, rd registryData) error {
-	cmd.ui.Info("\t[netlify] writing service discovery file...")
-	err := writeJSONFile(filepath.Join(cmd.outputDir, ".well-known/terraform.json"), wellKnownTerraform{
-		ProvidersV1: "/providers/v1/",
-	})
+// redirectsFile is the _redirects file shared by Netlify and Cloudflare Pages,
+// see https://docs.netlify.com/routing/redirects/ and
+// https://developers.cloudflare.com/pages/platform/redirects
+const redirectsFile = `
+# redirect the individual version requests
+/providers/v1/:namespace/:name/:version/download/:os/:arch	/providers/v1/:namespace/:name/:version-:os-:arch.json	200
+
+# redirect the versions list request
+/providers/v1/:namespace/:name/versions	/providers/v1/:namespace/:name/versions.json	200
+
+# redirect the extended API requests, only generated with -full-api
+/providers/v1/:namespace/:name/:version	/providers/v1/:namespace/:name/:version.json	200
+/providers/v1/:namespace/:name	/providers/v1/:namespace/:name/index.json	200
+/providers/v1/:namespace	/providers/v1/:namespace/index.json	200
+
+# redirect the module download requests
+/modules/v1/:namespace/:name/:system/:version/download	/modules/v1/:namespace/:name/:system/:version/download.json	200
+
+# redirect the module versions list request
+/modules/v1/:namespace/:name/:system/versions	/modules/v1/:namespace/:name/:system/versions.json	200
+`
+
+const netlifyCacheControl = "public, max-age=300"
+
+// netlifyJSONPaths are the registry documents, both the protocol paths and the
+// .json files they are rewritten to. Netlify serves .json files as JSON, but
+// proxies in front of the registry need explicit caching rules.
+var netlifyJSONPaths = []string{
+	"/.well-known/terraform.json",
+	"/providers/v1/*/versions",
+	"/providers/v1/*/download/*",
+	"/providers/v1/*.json",
+	"/modules/v1/*/versions",
+	"/modules/v1/*/download",
+	"/modules/v1/*.json",
+}
+
+// netlifyTOML is the subset of netlify.toml used to find the publish directory,
+// see https://docs.netlify.com/configure-builds/file-based-configuration/
+type netlifyTOML struct {
+	Build struct {
+		Publish string `toml:"publish"`
+	} `toml:"build"`
+}
+
+// readNetlifyPublishDir returns the publish directory from the netlify.toml
+// file in dir, if there is one.
+func readNetlifyPublishDir(dir string) (string, error) {
+	var conf netlifyTOML
+	file := filepath.Join(dir, "netlify.toml")
+	_, err := toml.DecodeFile(file, &conf)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
 	if err != nil {
-		return fmt.Errorf("unable to write service discovery file: %w", err)
+		return "", fmt.Errorf("unable to read %q: %w", file, err)
+	}
+	if conf.Build.Publish == "" {
+		return "", nil
 	}
+	return filepath.Join(dir, filepath.FromSlash(conf.Build.Publish)), nil
+}
 
-	cmd.ui.Info("\t[netlify] writing provider version files")
-	for k, v := range rd.ProviderVersions {
-		err = writeJSONFile(filepath.Join(
-			cmd.outputDir,
-			"providers/v1",
-			strings.ToLower(k.Namespace), strings.ToLower(k.Name),
-			"versions.json",
-		), v)
-		if err != nil {
-			return err
-		}
+// headerRule is a path and its headers in a _headers file.
+type headerRule struct {
+	Path    string
+	Headers [][2]string
+}
+
+func (cmd *generateCmd) generateNetlify(ctx context.Context, rd registryData, srv server) error {
+	err := cmd.generateSite(ctx, rd, rewriteLayout, srv)
+	if err != nil {
+		return err
 	}
 
-	cmd.ui.Info("\t[netlify] writing provider version download files...")
-	for k, v := range rd.Downloads {
-		err = writeJSONFile(filepath.Join(
-			cmd.outputDir,
-			"providers/v1",
-			strings.ToLower(k.Namespace), strings.ToLower(k.Name),
-			fmt.Sprintf("%s-%s-%s.json", k.Version, k.OS, k.Arch),
-		), v)
+	rules := []headerRule{}
+	for _, p := range netlifyJSONPaths {
+		rules = append(rules, headerRule{
+			Path: srv.prefixPaths(p),
+			Headers: [][2]string{
+				{"Content-Type", "application/json"},
+				{"Cache-Control", netlifyCacheControl},
+			},
+		})
 	}
+	rules = append(rules, moduleHeaderRules(rd, srv)...)
 
-	cmd.ui.Info("\t[netlify] writing redirects file...")
-	err = ioutil.WriteFile(
-		filepath.Join(
-			cmd.outputDir,
-			"_redirects",
-		),
-		[]byte(`
-# redirect the individual version requests
-/providers/v1/:namespace/:name/:version/download/:os/:arch	/providers/v1/:namespace/:name/:version-:os-:arch.json	200
+	cmd.ui.Info("\t[netlify] writing headers file...")
+	err = cmd.writeHeadersFile(filepath.Join(srv.Output, "_headers"), rules)
+	if err != nil {
+		return err
+	}
 
-# redirect the versions list request
-/providers/v1/:namespace/:name/versions	/providers/v1/:namespace/:name/versions.json	200
-`),
-		0644,
-	)
+	cmd.ui.Info("\t[netlify] writing redirects file...")
+	return cmd.writeRedirectsFile(filepath.Join(srv.Output, "_redirects"), srv)
+}
 
-	return nil
+// moduleHeaderRules sets the X-Terraform-Get header on module downloads,
+// older versions of Terraform only read the location from the header.
+func moduleHeaderRules(rd registryData, srv server) []headerRule {
+	rules := make([]headerRule, 0, len(rd.ModuleDownloads))
+	for k, v := range rd.ModuleDownloads {
+		rules = append(rules, headerRule{
+			Path: srv.urlPath(moduleDownloadPath(k)),
+			Headers: [][2]string{
+				{"X-Terraform-Get", v.Location},
+			},
+		})
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		return rules[i].Path < rules[j].Path
+	})
+	return rules
 }
 
-func writeJSONFile(file string, data interface{}) error {
-	bytes, err := json.MarshalIndent(data, "", "\t")
-	if err != nil {
-		return fmt.Errorf("unable to marshal JSON to write to file %q: %w", file, err)
+func (cmd *generateCmd) writeHeadersFile(file string, rules []headerRule) error {
+	var b strings.Builder
+	for _, r := range rules {
+		b.WriteString(r.Path + "\n")
+		for _, h := range r.Headers {
+			fmt.Fprintf(&b, "  %s: %s\n", h[0], h[1])
+		}
+		b.WriteString("\n")
 	}
-	dir := filepath.Dir(file)
-	err = os.MkdirAll(dir, 0644)
+
+	err := cmd.writeFile(file, []byte(b.String()))
 	if err != nil {
-		return fmt.Errorf("unable to make directory %q: %w", dir, err)
+		return fmt.Errorf("unable to write headers file: %w", err)
 	}
-	err = ioutil.WriteFile(file, bytes, 0644)
+	return nil
+}
+
+func (cmd *generateCmd) writeRedirectsFile(file string, srv server) error {
+	err := cmd.writeFile(file, []byte(srv.prefixPaths(redirectsFile)))
 	if err != nil {
-		return fmt.Errorf("unable to write file %q: %w", file, err)
+		return fmt.Errorf("unable to write redirects file: %w", err)
 	}
 	return nil
 }
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// see https://www.terraform.io/docs/internals/provider-network-mirror-protocol.html
+
+type networkMirrorVersions struct {
+	Versions map[string]struct{} `json:"versions"`
+}
+
+type networkMirrorArchives struct {
+	Archives map[string]networkMirrorArchive `json:"archives"`
+}
+
+type networkMirrorArchive struct {
+	URL    string   `json:"url"`
+	Hashes []string `json:"hashes,omitempty"`
+}
+
+func (cmd *generateCmd) providerHostname(k providerVersionsKey, rd registryData) (string, error) {
+	if host := rd.Hostnames[k]; host != "" {
+		return host, nil
+	}
+	if cmd.hostname == "" {
+		return "", fmt.Errorf("a hostname is required for provider \"%s/%s\"", k.Namespace, k.Name)
+	}
+	return cmd.hostname, nil
+}
+
+func (cmd *generateCmd) generateNetworkMirror(ctx context.Context, rd registryData, outputDir string) error {
+	cmd.ui.Info("\t[network-mirror] writing provider version files...")
+	for k, v := range rd.ProviderVersions {
+		host, err := cmd.providerHostname(k, rd)
+		if err != nil {
+			return err
+		}
+
+		dir := filepath.Join(
+			outputDir,
+			strings.ToLower(host),
+			strings.ToLower(k.Namespace), strings.ToLower(k.Name),
+		)
+
+		versions := networkMirrorVersions{
+			Versions: map[string]struct{}{},
+		}
+		for _, pv := range v.Versions {
+			versions.Versions[pv.Version] = struct{}{}
+
+			archives := networkMirrorArchives{
+				Archives: map[string]networkMirrorArchive{},
+			}
+			for _, plat := range pv.Platforms {
+				d, ok := rd.Downloads[providerDownloadKey{
+					Namespace: k.Namespace,
+					Name:      k.Name,
+					Version:   pv.Version,
+					OS:        plat.OS,
+					Arch:      plat.Arch,
+				}]
+				if !ok {
+					return fmt.Errorf("no download found for \"%s/%s\" %q \"%s/%s\"", k.Namespace, k.Name, pv.Version, plat.OS, plat.Arch)
+				}
+
+				archive := networkMirrorArchive{
+					URL: d.DownloadURL,
+				}
+				if d.Shasum != "" {
+					// the zip hash scheme is the SHA256 of the archive, same as the SHASUMS entry
+					archive.Hashes = []string{"zh:" + d.Shasum}
+				}
+				archives.Archives[fmt.Sprintf("%s_%s", plat.OS, plat.Arch)] = archive
+			}
+
+			err = cmd.writeJSONFile(filepath.Join(dir, pv.Version+".json"), archives)
+			if err != nil {
+				return err
+			}
+		}
+
+		err = cmd.writeJSONFile(filepath.Join(dir, "index.json"), versions)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,538 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/go-cleanhttp"
+	"github.com/hashicorp/go-version"
+	"github.com/mitchellh/cli"
+)
+
+type planCmd struct {
+	generateCmd
+
+	// compare against a deployed registry instead of the output directory
+	registryURL string
+
+	jsonOutput bool
+}
+
+func (cmd *planCmd) Synopsis() string {
+	return "shows the changes generating would make to a registry"
+}
+
+func (cmd *planCmd) Help() string {
+	return `Usage: tfstaticregistry plan [-output dist] [-url https://registry.example.com] [-json]
+
+  Collects the configured providers and compares them to the generated
+  output directory, or a deployed registry with -url. Added and removed
+  versions and platforms are listed, along with changed hashes, download
+  URLs and signing keys. With -json the changes are written as a JSON
+  document, for example for a pull request comment.`
+}
+
+func (cmd *planCmd) Flags() *flag.FlagSet {
+	fs := flag.NewFlagSet("plan", flag.ContinueOnError)
+	fs.StringVar(&cmd.serverType, "server", "", "type of server for the registry")
+	fs.StringVar(&cmd.outputDir, "output", "", "output directory of the generated registry")
+	fs.StringVar(&cmd.basePath, "base-path", "", "path of the registry on the host")
+	fs.StringVar(&cmd.registryURL, "url", "", "URL of a deployed registry to compare against")
+	fs.BoolVar(&cmd.jsonOutput, "json", false, "write the changes as JSON")
+	cmd.configFlags(fs)
+	return fs
+}
+
+func (cmd *planCmd) Run(args []string) int {
+	fs := cmd.Flags()
+	err := fs.Parse(args)
+	if err != nil {
+		cmd.ui.Error(fmt.Sprintf("unable to parse flags: %s", err))
+		return exitCodeConfig
+	}
+
+	if cmd.jsonOutput {
+		cmd.ui = quietUi{cmd.ui}
+	}
+
+	return cmd.run(cmd.runInternal)
+}
+
+// quietUi drops informational output, so only the JSON document is written.
+type quietUi struct {
+	cli.Ui
+}
+
+func (quietUi) Info(string) {}
+
+// planChange is an added, removed or changed version, platform or download
+// attribute of a provider.
+type planChange struct {
+	Action    string `json:"action"`
+	Provider  string `json:"provider"`
+	Version   string `json:"version"`
+	OS        string `json:"os,omitempty"`
+	Arch      string `json:"arch,omitempty"`
+	Attribute string `json:"attribute,omitempty"`
+	Before    string `json:"before,omitempty"`
+	After     string `json:"after,omitempty"`
+}
+
+type planSummary struct {
+	Add    int `json:"add"`
+	Remove int `json:"remove"`
+	Change int `json:"change"`
+}
+
+type plan struct {
+	Changes []planChange `json:"changes"`
+	Summary planSummary  `json:"summary"`
+}
+
+// planSource is a registry the plan compares, provider names are lower case
+// namespace/name.
+type planSource interface {
+	providers(ctx context.Context) ([]string, error)
+	versions(ctx context.Context, name string) (providerVersionsIndex, bool, error)
+	download(ctx context.Context, k providerDownloadKey) (providerDownloadIndex, error)
+}
+
+func (cmd *planCmd) runInternal() error {
+	ctx := context.Background()
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	conf, err := cmd.loadConfig()
+	if err != nil {
+		return err
+	}
+
+	var before planSource
+	if cmd.registryURL != "" {
+		cmd.ui.Info(fmt.Sprintf("Comparing to %s...", cmd.registryURL))
+		client, _, err := newRegistryClient(ctx, cleanhttp.DefaultClient(), cmd.registryURL)
+		if err != nil {
+			return err
+		}
+		before = &urlPlanSource{client: client}
+	} else {
+		srv := server{
+			Type:     cmd.serverType,
+			Output:   cmd.outputDir,
+			BasePath: cmd.basePath,
+		}
+		if cmd.serverType == "" && cmd.outputDir == "" && len(conf.Servers) > 0 {
+			srv = conf.Servers[0]
+		}
+		err = srv.setDefaults(cwd)
+		if err != nil {
+			return err
+		}
+		if srv.Format != "registry" {
+			return fmt.Errorf("plan only supports the registry format, not %q", srv.Format)
+		}
+		cmd.ui.Info(fmt.Sprintf("Comparing to %q...", srv.Output))
+		before = dirPlanSource(srv.registryDir())
+	}
+
+	rd, err := cmd.collect(ctx, conf)
+	if err != nil {
+		return err
+	}
+	after := dataPlanSource(rd)
+
+	if client, ok := before.(*urlPlanSource); ok {
+		// a deployed registry can only be listed for the collected providers
+		client.names, err = after.providers(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	p, err := diffRegistries(ctx, before, after)
+	if err != nil {
+		return err
+	}
+
+	if cmd.jsonOutput {
+		out, err := json.MarshalIndent(p, "", "\t")
+		if err != nil {
+			return err
+		}
+		cmd.ui.Output(string(out))
+		return nil
+	}
+
+	cmd.ui.Info("")
+	if len(p.Changes) == 0 {
+		cmd.ui.Output("No changes, the registry is up to date.")
+		return nil
+	}
+	for _, c := range p.Changes {
+		cmd.ui.Output(c.String())
+	}
+	cmd.ui.Output(fmt.Sprintf("\nPlan: %d to add, %d to remove, %d to change.", p.Summary.Add, p.Summary.Remove, p.Summary.Change))
+	return nil
+}
+
+func (c planChange) String() string {
+	symbol := map[string]string{"add": "+", "remove": "-", "change": "~"}[c.Action]
+	s := fmt.Sprintf("  %s %s %s", symbol, c.Provider, c.Version)
+	if c.OS != "" {
+		s += fmt.Sprintf(" %s_%s", c.OS, c.Arch)
+	}
+	if c.Attribute != "" {
+		s += fmt.Sprintf(" %s: %q -> %q", c.Attribute, c.Before, c.After)
+	}
+	return s
+}
+
+// diffRegistries lists the changes from before to after. Versions are only
+// compared by platform, and platforms by attribute, if they are in both.
+func diffRegistries(ctx context.Context, before, after planSource) (plan, error) {
+	p := plan{
+		Changes: []planChange{},
+	}
+
+	names := map[string]bool{}
+	for _, src := range []planSource{before, after} {
+		list, err := src.providers(ctx)
+		if err != nil {
+			return p, err
+		}
+		for _, n := range list {
+			names[n] = true
+		}
+	}
+
+	sorted := make([]string, 0, len(names))
+	for n := range names {
+		sorted = append(sorted, n)
+	}
+	sort.Strings(sorted)
+
+	for _, name := range sorted {
+		bv, _, err := before.versions(ctx, name)
+		if err != nil {
+			return p, err
+		}
+		av, _, err := after.versions(ctx, name)
+		if err != nil {
+			return p, err
+		}
+
+		bVersions, aVersions := versionPlatforms(bv), versionPlatforms(av)
+		for _, v := range planVersions(bVersions, aVersions) {
+			bp, inBefore := bVersions[v]
+			ap, inAfter := aVersions[v]
+			switch {
+			case !inBefore:
+				p.Changes = append(p.Changes, planChange{Action: "add", Provider: name, Version: v})
+				continue
+			case !inAfter:
+				p.Changes = append(p.Changes, planChange{Action: "remove", Provider: name, Version: v})
+				continue
+			}
+
+			for _, plat := range planPlatforms(bp, ap) {
+				c := planChange{Provider: name, Version: v, OS: plat.OS, Arch: plat.Arch}
+				switch {
+				case !bp[plat]:
+					c.Action = "add"
+					p.Changes = append(p.Changes, c)
+					continue
+				case !ap[plat]:
+					c.Action = "remove"
+					p.Changes = append(p.Changes, c)
+					continue
+				}
+
+				k := providerDownloadKey{Version: v, OS: plat.OS, Arch: plat.Arch}
+				k.Namespace, k.Name = splitProviderName(name)
+				bd, err := before.download(ctx, k)
+				if err != nil {
+					return p, err
+				}
+				ad, err := after.download(ctx, k)
+				if err != nil {
+					return p, err
+				}
+				for _, attr := range downloadChanges(bd, ad) {
+					c.Action, c.Attribute, c.Before, c.After = "change", attr[0], attr[1], attr[2]
+					p.Changes = append(p.Changes, c)
+				}
+			}
+		}
+	}
+
+	for _, c := range p.Changes {
+		switch c.Action {
+		case "add":
+			p.Summary.Add++
+		case "remove":
+			p.Summary.Remove++
+		case "change":
+			p.Summary.Change++
+		}
+	}
+	return p, nil
+}
+
+func versionPlatforms(vi providerVersionsIndex) map[string]map[platform]bool {
+	versions := map[string]map[platform]bool{}
+	for _, v := range vi.Versions {
+		platforms := map[platform]bool{}
+		for _, plat := range v.Platforms {
+			platforms[plat] = true
+		}
+		versions[v.Version] = platforms
+	}
+	return versions
+}
+
+// planVersions returns the versions of both sides, sorted by precedence.
+func planVersions(before, after map[string]map[platform]bool) []string {
+	versions := []string{}
+	for v := range before {
+		versions = append(versions, v)
+	}
+	for v := range after {
+		if _, ok := before[v]; !ok {
+			versions = append(versions, v)
+		}
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		vi, erri := version.NewVersion(versions[i])
+		vj, errj := version.NewVersion(versions[j])
+		if erri != nil || errj != nil {
+			return versions[i] < versions[j]
+		}
+		return vi.LessThan(vj)
+	})
+	return versions
+}
+
+func planPlatforms(before, after map[platform]bool) []platform {
+	all := map[platform]bool{}
+	for plat := range before {
+		all[plat] = true
+	}
+	for plat := range after {
+		all[plat] = true
+	}
+	platforms := make([]platform, 0, len(all))
+	for plat := range all {
+		platforms = append(platforms, plat)
+	}
+	sort.Slice(platforms, func(i, j int) bool {
+		if platforms[i].OS != platforms[j].OS {
+			return platforms[i].OS < platforms[j].OS
+		}
+		return platforms[i].Arch < platforms[j].Arch
+	})
+	return platforms
+}
+
+// downloadChanges returns the attribute, before and after value of each
+// changed attribute.
+func downloadChanges(before, after providerDownloadIndex) [][3]string {
+	attrs := [][3]string{
+		{"filename", before.Filename, after.Filename},
+		{"shasum", before.Shasum, after.Shasum},
+		{"download_url", before.DownloadURL, after.DownloadURL},
+		{"signing_keys", signingKeyIDs(before.SigningKeys), signingKeyIDs(after.SigningKeys)},
+	}
+	changes := [][3]string{}
+	for _, a := range attrs {
+		if a[1] != a[2] {
+			changes = append(changes, a)
+		}
+	}
+	return changes
+}
+
+func signingKeyIDs(keys signingKeys) string {
+	ids := []string{}
+	for _, k := range keys.GPGPublicKeys {
+		ids = append(ids, k.KeyID)
+	}
+	sort.Strings(ids)
+	return strings.Join(ids, ",")
+}
+
+func splitProviderName(name string) (string, string) {
+	parts := strings.SplitN(name, "/", 2)
+	if len(parts) != 2 {
+		return name, ""
+	}
+	return parts[0], parts[1]
+}
+
+// dataPlanSource is the collected registry data.
+type dataPlanSource registryData
+
+func (rd dataPlanSource) providers(ctx context.Context) ([]string, error) {
+	names := []string{}
+	for k := range rd.ProviderVersions {
+		names = append(names, strings.ToLower(k.Namespace+"/"+k.Name))
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (rd dataPlanSource) versions(ctx context.Context, name string) (providerVersionsIndex, bool, error) {
+	for k, v := range rd.ProviderVersions {
+		if strings.EqualFold(k.Namespace+"/"+k.Name, name) {
+			return v, true, nil
+		}
+	}
+	return providerVersionsIndex{}, false, nil
+}
+
+func (rd dataPlanSource) download(ctx context.Context, k providerDownloadKey) (providerDownloadIndex, error) {
+	for dk, d := range rd.Downloads {
+		if strings.EqualFold(dk.Namespace, k.Namespace) && strings.EqualFold(dk.Name, k.Name) &&
+			dk.Version == k.Version && dk.OS == k.OS && dk.Arch == k.Arch {
+			return d, nil
+		}
+	}
+	return providerDownloadIndex{}, fmt.Errorf("no download collected for %s/%s %s %s_%s", k.Namespace, k.Name, k.Version, k.OS, k.Arch)
+}
+
+// dirPlanSource is the registry directory of a generated static site, in
+// either layout.
+type dirPlanSource string
+
+func (dir dirPlanSource) providers(ctx context.Context) ([]string, error) {
+	root := filepath.Join(string(dir), "providers", "v1")
+	namespaces, err := ioutil.ReadDir(root)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for _, ns := range namespaces {
+		if !ns.IsDir() {
+			continue
+		}
+		providers, err := ioutil.ReadDir(filepath.Join(root, ns.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range providers {
+			if !p.IsDir() {
+				continue
+			}
+			name := ns.Name() + "/" + p.Name()
+			if _, ok, _ := dir.versions(ctx, name); ok {
+				names = append(names, name)
+			}
+		}
+	}
+	return names, nil
+}
+
+func (dir dirPlanSource) versions(ctx context.Context, name string) (providerVersionsIndex, bool, error) {
+	var vi providerVersionsIndex
+	k := providerVersionsKey{}
+	k.Namespace, k.Name = splitProviderName(name)
+	ok, err := dir.readDocument(&vi, rewriteLayout.providerVersionsFile(k), exactLayout.providerVersionsFile(k))
+	return vi, ok, err
+}
+
+func (dir dirPlanSource) download(ctx context.Context, k providerDownloadKey) (providerDownloadIndex, error) {
+	var d providerDownloadIndex
+	ok, err := dir.readDocument(&d, rewriteLayout.providerDownloadFile(k), exactLayout.providerDownloadFile(k))
+	if err == nil && !ok {
+		err = fmt.Errorf("no download document for %s/%s %s %s_%s", k.Namespace, k.Name, k.Version, k.OS, k.Arch)
+	}
+	return d, err
+}
+
+// readDocument reads the first of the files that exists.
+func (dir dirPlanSource) readDocument(data interface{}, files ...string) (bool, error) {
+	for _, f := range files {
+		file := filepath.Join(string(dir), filepath.FromSlash(f))
+		info, err := os.Stat(file)
+		if os.IsNotExist(err) || (err == nil && info.IsDir()) {
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+
+		body, err := ioutil.ReadFile(file)
+		if err != nil {
+			return false, fmt.Errorf("unable to read %q: %w", file, err)
+		}
+		err = json.Unmarshal(body, data)
+		if err != nil {
+			return false, fmt.Errorf("unable to unmarshal %q: %w", file, err)
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// urlPlanSource is a deployed registry, only the given providers and the
+// providers of their namespace listings, if there are any, are compared.
+type urlPlanSource struct {
+	client *registryClient
+	names  []string
+}
+
+func (s *urlPlanSource) providers(ctx context.Context) ([]string, error) {
+	names := map[string]bool{}
+	listed := map[string]bool{}
+	for _, n := range s.names {
+		ns, _ := splitProviderName(n)
+		if !listed[ns] {
+			listed[ns] = true
+			// the listing is only generated with -full-api
+			nsNames, err := s.client.namespaceProviders(ctx, ns)
+			if err == nil {
+				for _, l := range nsNames {
+					names[strings.ToLower(l)] = true
+				}
+			}
+		}
+		if _, ok, err := s.versions(ctx, n); err != nil {
+			return nil, err
+		} else if ok {
+			names[n] = true
+		}
+	}
+
+	list := make([]string, 0, len(names))
+	for n := range names {
+		list = append(list, n)
+	}
+	return list, nil
+}
+
+func (s *urlPlanSource) versions(ctx context.Context, name string) (providerVersionsIndex, bool, error) {
+	vi, err := s.client.providerVersions(ctx, name)
+	if errors.Is(err, errNotFound) {
+		return vi, false, nil
+	}
+	return vi, err == nil, err
+}
+
+func (s *urlPlanSource) download(ctx context.Context, k providerDownloadKey) (providerDownloadIndex, error) {
+	_, d, err := s.client.providerDownload(ctx, k.Namespace+"/"+k.Name, k.Version, k.OS, k.Arch)
+	return d, err
+}
@@ -0,0 +1,182 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-version"
+)
+
+// The extended registry API is not used by terraform init, but is useful for
+// tooling and UIs browsing the registry. The document formats follow the
+// public Terraform Registry.
+
+type providerDetails struct {
+	Description string
+	Source      string
+	Tier        string
+	Versions    map[string]providerVersionDetails
+}
+
+type providerVersionDetails struct {
+	Tag         string
+	PublishedAt time.Time
+	Docs        []providerDoc
+}
+
+type providerList struct {
+	Meta      providerListMeta   `json:"meta"`
+	Providers []providerMetadata `json:"providers"`
+}
+
+type providerListMeta struct {
+	Limit         int `json:"limit"`
+	CurrentOffset int `json:"current_offset"`
+}
+
+type providerMetadata struct {
+	ID          string        `json:"id"`
+	Owner       string        `json:"owner"`
+	Namespace   string        `json:"namespace"`
+	Name        string        `json:"name"`
+	Alias       string        `json:"alias"`
+	Version     string        `json:"version"`
+	Tag         string        `json:"tag"`
+	Description string        `json:"description"`
+	Source      string        `json:"source"`
+	PublishedAt time.Time     `json:"published_at"`
+	Downloads   int           `json:"downloads"`
+	Tier        string        `json:"tier"`
+	Versions    []string      `json:"versions,omitempty"`
+	Docs        []providerDoc `json:"docs,omitempty"`
+}
+
+type providerDoc struct {
+	ID          string `json:"id"`
+	Title       string `json:"title"`
+	Path        string `json:"path"`
+	Slug        string `json:"slug"`
+	Category    string `json:"category"`
+	Subcategory string `json:"subcategory,omitempty"`
+	Language    string `json:"language"`
+
+	// Content is the Markdown of the page, without front matter
+	Content string `json:"-"`
+}
+
+// sortedVersions returns the versions of a provider in ascending semver order.
+func sortedVersions(vi providerVersionsIndex) []*version.Version {
+	versions := make([]*version.Version, 0, len(vi.Versions))
+	for _, pv := range vi.Versions {
+		v, err := version.NewVersion(pv.Version)
+		if err != nil {
+			continue
+		}
+		versions = append(versions, v)
+	}
+	sort.Sort(version.Collection(versions))
+	return versions
+}
+
+// latestVersion returns the newest non-prerelease version, falling back to
+// the newest prerelease if there are no releases.
+func latestVersion(versions []*version.Version) *version.Version {
+	for i := len(versions) - 1; i >= 0; i-- {
+		if versions[i].Prerelease() == "" {
+			return versions[i]
+		}
+	}
+	if len(versions) == 0 {
+		return nil
+	}
+	return versions[len(versions)-1]
+}
+
+func newProviderMetadata(k providerVersionsKey, details providerDetails, v *version.Version) providerMetadata {
+	ver := v.Original()
+	vd := details.Versions[ver]
+
+	tier := details.Tier
+	if tier == "" {
+		tier = "community"
+	}
+
+	return providerMetadata{
+		ID:          fmt.Sprintf("%s/%s/%s", k.Namespace, k.Name, ver),
+		Owner:       k.Namespace,
+		Namespace:   k.Namespace,
+		Name:        k.Name,
+		Alias:       k.Name,
+		Version:     ver,
+		Tag:         vd.Tag,
+		Description: details.Description,
+		Source:      details.Source,
+		PublishedAt: vd.PublishedAt,
+		Tier:        tier,
+		Docs:        vd.Docs,
+	}
+}
+
+func (cmd *generateCmd) generateProviderAPI(ctx context.Context, rd registryData, outputDir string) error {
+	namespaces := map[string][]providerMetadata{}
+
+	for k, vi := range rd.ProviderVersions {
+		details := rd.ProviderDetails[k]
+		dir := filepath.Join(
+			outputDir,
+			"providers/v1",
+			strings.ToLower(k.Namespace), strings.ToLower(k.Name),
+		)
+
+		versions := sortedVersions(vi)
+		latest := latestVersion(versions)
+		if latest == nil {
+			continue
+		}
+
+		versionStrings := make([]string, 0, len(versions))
+		for _, v := range versions {
+			versionStrings = append(versionStrings, v.Original())
+
+			err := cmd.writeJSONFile(filepath.Join(dir, v.Original()+".json"), newProviderMetadata(k, details, v))
+			if err != nil {
+				return err
+			}
+		}
+
+		md := newProviderMetadata(k, details, latest)
+		ns := strings.ToLower(k.Namespace)
+		namespaces[ns] = append(namespaces[ns], md)
+
+		md.Versions = versionStrings
+		err := cmd.writeJSONFile(filepath.Join(dir, "index.json"), md)
+		if err != nil {
+			return err
+		}
+	}
+
+	for ns, providers := range namespaces {
+		sort.Slice(providers, func(i, j int) bool {
+			return providers[i].Name < providers[j].Name
+		})
+		for i := range providers {
+			providers[i].Docs = nil
+		}
+
+		err := cmd.writeJSONFile(filepath.Join(outputDir, "providers/v1", ns, "index.json"), providerList{
+			Meta: providerListMeta{
+				Limit: len(providers),
+			},
+			Providers: providers,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
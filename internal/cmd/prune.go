@@ -0,0 +1,247 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// manifestFile lists the files written to an output directory by the last
+// run, only files in the manifest are ever pruned.
+const manifestFile = ".tfstaticregistry-manifest.json"
+
+type manifest struct {
+	Files []string `json:"files"`
+}
+
+// validManifestPath reports whether a manifest entry is a relative path
+// inside the output directory.
+func validManifestPath(f string) bool {
+	if f == "" || path.IsAbs(f) || filepath.IsAbs(f) || strings.Contains(f, "\\") {
+		return false
+	}
+	for _, part := range strings.Split(f, "/") {
+		if part == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+func (cmd *generateCmd) markWritten(file string) {
+	if cmd.written == nil {
+		return
+	}
+	cmd.written[filepath.Clean(file)] = true
+}
+
+// parseManifest reads a manifest, rejecting entries outside of the output
+// directory so a tampered manifest can't delete other files.
+func parseManifest(name string, body []byte) (manifest, error) {
+	var m manifest
+	err := json.Unmarshal(body, &m)
+	if err != nil {
+		return m, fmt.Errorf("unable to parse manifest %q: %w", name, err)
+	}
+	for _, f := range m.Files {
+		if !validManifestPath(f) {
+			return m, fmt.Errorf("manifest %q has an invalid path %q", name, f)
+		}
+	}
+	return m, nil
+}
+
+// staleFiles returns the files of the previous manifest not in current.
+func staleFiles(previous manifest, current []string) []string {
+	written := map[string]bool{}
+	for _, f := range current {
+		written[f] = true
+	}
+
+	stale := []string{}
+	for _, f := range previous.Files {
+		if !written[f] {
+			stale = append(stale, f)
+		}
+	}
+	return stale
+}
+
+// prune deletes files written by the previous run that were not written by
+// this one, then records the written files in the manifest. It returns the
+// stale files, with -dry-run they are only listed.
+func (cmd *generateCmd) prune(srv server) ([]string, error) {
+	current := manifest{
+		Files: []string{},
+	}
+	for f := range cmd.written {
+		rel, err := filepath.Rel(srv.Output, f)
+		if err != nil {
+			return nil, err
+		}
+		current.Files = append(current.Files, filepath.ToSlash(rel))
+	}
+	sort.Strings(current.Files)
+	cmd.files = current.Files
+
+	// the output directory is not copied for a dry run in place
+	file := filepath.Join(srv.targetDir(), manifestFile)
+
+	var previous manifest
+	body, err := ioutil.ReadFile(file)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return nil, fmt.Errorf("unable to read manifest %q: %w", file, err)
+	default:
+		previous, err = parseManifest(file, body)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	stale := staleFiles(previous, current.Files)
+	if len(stale) > 0 {
+		cmd.ui.Info(fmt.Sprintf("\t[%s] pruning %d stale files...", srv.Type, len(stale)))
+	}
+	for _, f := range stale {
+		if cmd.dryRun {
+			cmd.log(logEvent{
+				Level:   "info",
+				Action:  "would_delete",
+				Server:  srv.Type,
+				File:    f,
+				Message: fmt.Sprintf("\t\twould delete %q", filepath.Join(srv.targetDir(), filepath.FromSlash(f))),
+			})
+			continue
+		}
+
+		path := filepath.Join(srv.Output, filepath.FromSlash(f))
+		cmd.log(logEvent{
+			Level:   "info",
+			Action:  "delete",
+			Server:  srv.Type,
+			File:    f,
+			Message: fmt.Sprintf("\t\tdeleting %q", filepath.Join(srv.targetDir(), filepath.FromSlash(f))),
+		})
+		err = os.Remove(path)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("unable to delete %q: %w", path, err)
+		}
+		removeEmptyDirs(srv.Output, filepath.Dir(path))
+	}
+
+	if cmd.dryRun {
+		return stale, nil
+	}
+
+	body, err = json.MarshalIndent(current, "", "\t")
+	if err != nil {
+		return nil, err
+	}
+	err = replaceFile(filepath.Join(srv.Output, manifestFile), body, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("unable to write manifest %q: %w", file, err)
+	}
+	return stale, nil
+}
+
+// removeEmptyDirs removes dir and its parents up to root while they are empty.
+func removeEmptyDirs(root, dir string) {
+	root = filepath.Clean(root)
+	for dir = filepath.Clean(dir); dir != root && len(dir) > len(root); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			return
+		}
+	}
+}
+
+// remoteStore is a storage service the output directory is uploaded to, with
+// objects named by their slash separated path in the output directory. get
+// returns an error wrapping errNotFound for a missing object.
+type remoteStore struct {
+	get func(name string) ([]byte, error)
+	put func(name string, body []byte) error
+	del func(name string) error
+}
+
+// publishRemote uploads the output directory to a storage service and
+// deletes the objects of the previous run that are no longer generated. The
+// manifest is kept in the storage service itself, and only replaced once
+// everything else succeeded, so a failed run is pruned by the next one. With
+// -dry-run nothing is uploaded and the stale objects are only listed.
+func (cmd *generateCmd) publishRemote(srv server, store remoteStore) error {
+	var previous manifest
+	body, err := store.get(manifestFile)
+	switch {
+	case errors.Is(err, errNotFound):
+	case err != nil:
+		return fmt.Errorf("unable to read manifest: %w", err)
+	default:
+		previous, err = parseManifest(manifestFile, body)
+		if err != nil {
+			return err
+		}
+	}
+
+	stale := staleFiles(previous, cmd.files)
+	cmd.report.addRemoteStale(stale)
+
+	if !cmd.dryRun {
+		err = walkSiteFiles(srv.Output, func(name string, body []byte) error {
+			cmd.ui.Info(fmt.Sprintf("\t\tuploading %q...", name))
+			return store.put(name, body)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(stale) > 0 {
+		cmd.ui.Info(fmt.Sprintf("\t[%s] pruning %d stale objects...", srv.Type, len(stale)))
+	}
+	for _, f := range stale {
+		if cmd.dryRun {
+			cmd.log(logEvent{
+				Level:   "info",
+				Action:  "would_delete",
+				Server:  srv.Type,
+				File:    f,
+				Message: fmt.Sprintf("\t\twould delete %q", f),
+			})
+			continue
+		}
+
+		cmd.log(logEvent{
+			Level:   "info",
+			Action:  "delete",
+			Server:  srv.Type,
+			File:    f,
+			Message: fmt.Sprintf("\t\tdeleting %q", f),
+		})
+		err := store.del(f)
+		if err != nil {
+			return err
+		}
+	}
+
+	if cmd.dryRun {
+		return nil
+	}
+
+	body, err = json.MarshalIndent(manifest{Files: cmd.files}, "", "\t")
+	if err != nil {
+		return err
+	}
+	err = store.put(manifestFile, body)
+	if err != nil {
+		return fmt.Errorf("unable to write manifest: %w", err)
+	}
+	return nil
+}
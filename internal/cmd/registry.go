@@ -15,12 +15,35 @@ var (
 )
 
 type wellKnownTerraform struct {
-	ProvidersV1 string `json:"providers.v1"`
+	ProvidersV1 string   `json:"providers.v1"`
+	ModulesV1   string   `json:"modules.v1,omitempty"`
+	LoginV1     *loginV1 `json:"login.v1,omitempty"`
+}
+
+// see https://www.terraform.io/docs/internals/login-protocol.html
+type loginV1 struct {
+	Client     string   `hcl:"client" json:"client"`
+	GrantTypes []string `hcl:"grant_types,optional" json:"grant_types,omitempty"`
+	Authz      string   `hcl:"authz,optional" json:"authz,omitempty"`
+	Token      string   `hcl:"token" json:"token"`
+	Ports      []int    `hcl:"ports,optional" json:"ports,omitempty"`
+	Scopes     []string `hcl:"scopes,optional" json:"scopes,omitempty"`
 }
 
 type registryData struct {
 	ProviderVersions map[providerVersionsKey]providerVersionsIndex
 	Downloads        map[providerDownloadKey]providerDownloadIndex
+
+	// Hostnames holds the origin hostname of providers sourced from another registry
+	Hostnames map[providerVersionsKey]string
+
+	// ProviderDetails holds metadata only used by the extended registry API
+	ProviderDetails map[providerVersionsKey]providerDetails
+
+	ModuleVersions  map[moduleVersionsKey]moduleVersionsIndex
+	ModuleDownloads map[moduleDownloadKey]moduleDownload
+
+	Login *loginV1
 }
 
 type providerVersionsKey struct {
@@ -116,9 +139,25 @@ func (cmd *generateCmd) collectRegistryProvider(ctx context.Context, p provider,
 		Namespace: namespace,
 		Name:      name,
 	}] = versions
+	rd.Hostnames[providerVersionsKey{
+		Namespace: namespace,
+		Name:      name,
+	}] = host
+
+	details := providerDetails{
+		Description: p.Description,
+		Tier:        p.Tier,
+		Versions:    map[string]providerVersionDetails{},
+	}
 
 	for _, v := range versions.Versions {
-		cmd.ui.Info(fmt.Sprintf("\t[%q] fetching version %q...", p, v.Version))
+		cmd.log(logEvent{
+			Level:    "info",
+			Action:   "collect",
+			Provider: p.String(),
+			Version:  v.Version,
+			Message:  fmt.Sprintf("\t[%q] fetching version %q...", p, v.Version),
+		})
 		for _, plat := range v.Platforms {
 			var downloadIndex providerDownloadIndex
 			err := getJSON(ctx, cmd.httpClient,
@@ -134,6 +173,13 @@ func (cmd *generateCmd) collectRegistryProvider(ctx context.Context, p provider,
 			if err != nil {
 				return fmt.Errorf("unable to get download info for %q \"%s/%s\": %w", v.Version, plat.OS, plat.Arch, err)
 			}
+			cmd.log(logEvent{
+				Level:    "debug",
+				Action:   "collect_platform",
+				Provider: p.String(),
+				Version:  v.Version,
+				Platform: plat.OS + "_" + plat.Arch,
+			})
 
 			rd.Downloads[providerDownloadKey{
 				Namespace: namespace,
@@ -143,18 +189,55 @@ func (cmd *generateCmd) collectRegistryProvider(ctx context.Context, p provider,
 				Arch:      plat.Arch,
 			}] = downloadIndex
 		}
+
+		if cmd.fullAPI {
+			var md providerMetadata
+			err := getJSON(ctx, cmd.httpClient,
+				fmt.Sprintf("https://%s/%s/%s/%s/%s",
+					host,
+					wk.ProvidersV1,
+					strings.ToLower(namespace),
+					strings.ToLower(name),
+					v.Version,
+				), &md)
+			if err != nil {
+				cmd.ui.Warn(fmt.Sprintf("\t[%q] unable to fetch metadata for version %q: %s", p, v.Version, err))
+				continue
+			}
+
+			if details.Description == "" {
+				details.Description = md.Description
+			}
+			if details.Tier == "" {
+				details.Tier = md.Tier
+			}
+			details.Source = md.Source
+			details.Versions[v.Version] = providerVersionDetails{
+				Tag:         md.Tag,
+				PublishedAt: md.PublishedAt,
+			}
+		}
 	}
 
+	rd.ProviderDetails[providerVersionsKey{
+		Namespace: namespace,
+		Name:      name,
+	}] = details
+
 	return nil
 }
 
 func getJSON(ctx context.Context, client *http.Client, url string, data interface{}) error {
 	resp, err := client.Get(url)
 	if err != nil {
-		return fmt.Errorf("unable to GET JSON file: %w", err)
+		return upstreamError(fmt.Errorf("unable to GET JSON file: %w", err))
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode != http.StatusOK {
+		return statusError(resp.StatusCode, fmt.Errorf("unexpected status code %d for %q", resp.StatusCode, url))
+	}
+
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
 		return fmt.Errorf("unable to read JSON body: %w", err)
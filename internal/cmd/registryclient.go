@@ -0,0 +1,203 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// errNotFound is wrapped by requests for documents that do not exist.
+var errNotFound = errors.New("not found")
+
+// registryClient reads a deployed registry over HTTP the way Terraform does.
+type registryClient struct {
+	httpClient   *http.Client
+	providersURL *url.URL
+
+	// bearer token sent to the registry host
+	host  string
+	token string
+}
+
+// newRegistryClient performs service discovery for the registry, a bearer
+// token is read from TF_TOKEN_<hostname> like Terraform, see registryToken.
+func newRegistryClient(ctx context.Context, httpClient *http.Client, registry string) (*registryClient, wellKnownTerraform, error) {
+	var wk wellKnownTerraform
+
+	if !strings.Contains(registry, "://") {
+		registry = "https://" + registry
+	}
+	base, err := url.Parse(registry)
+	if err != nil || base.Host == "" {
+		return nil, wk, configError(fmt.Errorf("invalid registry URL %q", registry))
+	}
+
+	c := &registryClient{
+		httpClient: httpClient,
+		host:       base.Host,
+		token:      registryToken(base.Hostname()),
+	}
+
+	discoveryURL := base.ResolveReference(&url.URL{Path: "/.well-known/terraform.json"})
+	err = c.getDiscovery(ctx, discoveryURL, &wk)
+	if err != nil {
+		return nil, wk, fmt.Errorf("unable to get service discovery information: %w", err)
+	}
+	if wk.ProvidersV1 == "" {
+		return nil, wk, fmt.Errorf("service discovery at %q has no providers.v1 service", discoveryURL)
+	}
+	c.providersURL, err = discoveryURL.Parse(wk.ProvidersV1)
+	if err != nil {
+		return nil, wk, fmt.Errorf("invalid providers.v1 service %q: %w", wk.ProvidersV1, err)
+	}
+	if !strings.HasSuffix(c.providersURL.Path, "/") {
+		c.providersURL.Path += "/"
+	}
+	return c, wk, nil
+}
+
+// registryToken returns the token for a registry host from the environment
+// like Terraform, where periods in the hostname are encoded as underscores
+// and hyphens as double underscores, for example TF_TOKEN_my__registry_com.
+func registryToken(hostname string) string {
+	for _, env := range os.Environ() {
+		parts := strings.SplitN(env, "=", 2)
+		if len(parts) != 2 || !strings.HasPrefix(parts[0], "TF_TOKEN_") {
+			continue
+		}
+		host := strings.TrimPrefix(parts[0], "TF_TOKEN_")
+		host = strings.ReplaceAll(host, "__", "-")
+		host = strings.ReplaceAll(host, "_", ".")
+		if strings.EqualFold(host, hostname) {
+			return parts[1]
+		}
+	}
+	return ""
+}
+
+// getDiscovery reads the service discovery document, which Terraform only
+// accepts when it is served as JSON.
+func (c *registryClient) getDiscovery(ctx context.Context, u *url.URL, wk *wellKnownTerraform) error {
+	resp, err := c.do(ctx, http.MethodGet, u)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	contentType := resp.Header.Get("Content-Type")
+	if mediaType, _, err := mime.ParseMediaType(contentType); err != nil || mediaType != "application/json" {
+		return fmt.Errorf("%q is served as %q, Terraform requires application/json", u, contentType)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("unable to read %q: %w", u, err)
+	}
+	err = json.Unmarshal(body, wk)
+	if err != nil {
+		return fmt.Errorf("unable to unmarshal %q: %w", u, err)
+	}
+	return nil
+}
+
+func (c *registryClient) providerVersions(ctx context.Context, name string) (providerVersionsIndex, error) {
+	var versions providerVersionsIndex
+	err := c.getJSON(ctx, c.providersURL.ResolveReference(&url.URL{Path: name + "/versions"}), &versions)
+	return versions, err
+}
+
+// providerDownload returns the download document of a platform and its URL,
+// relative URLs in the document are relative to it.
+func (c *registryClient) providerDownload(ctx context.Context, name, version, os, arch string) (*url.URL, providerDownloadIndex, error) {
+	var d providerDownloadIndex
+	u := c.providersURL.ResolveReference(&url.URL{
+		Path: fmt.Sprintf("%s/%s/download/%s/%s", name, version, os, arch),
+	})
+	err := c.getJSON(ctx, u, &d)
+	return u, d, err
+}
+
+// namespaceProviders lists the providers of a namespace from the extended
+// registry API.
+func (c *registryClient) namespaceProviders(ctx context.Context, namespace string) ([]string, error) {
+	var list providerList
+	err := c.getJSON(ctx, c.providersURL.ResolveReference(&url.URL{Path: url.PathEscape(namespace)}), &list)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list providers, was the registry generated with -full-api? %w", err)
+	}
+
+	names := []string{}
+	for _, p := range list.Providers {
+		names = append(names, p.Namespace+"/"+p.Name)
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no providers found in namespace %q", namespace)
+	}
+	return names, nil
+}
+
+func (c *registryClient) getJSON(ctx context.Context, u *url.URL, data interface{}) error {
+	body, err := c.get(ctx, u)
+	if err != nil {
+		return err
+	}
+	err = json.Unmarshal(body, data)
+	if err != nil {
+		return fmt.Errorf("unable to unmarshal %q: %w", u, err)
+	}
+	return nil
+}
+
+func (c *registryClient) get(ctx context.Context, u *url.URL) ([]byte, error) {
+	resp, err := c.do(ctx, http.MethodGet, u)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %q: %w", u, err)
+	}
+	return body, nil
+}
+
+func (c *registryClient) head(ctx context.Context, u *url.URL) error {
+	resp, err := c.do(ctx, http.MethodHead, u)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func (c *registryClient) do(ctx context.Context, method string, u *url.URL) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	// only send the token to the registry itself, downloads are often hosted elsewhere
+	if c.token != "" && strings.EqualFold(req.URL.Host, c.host) {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, upstreamError(fmt.Errorf("unable to %s %q: %w", method, u, err))
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, upstreamError(fmt.Errorf("unable to %s %q: %w", method, u, errNotFound))
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		resp.Body.Close()
+		return nil, statusError(resp.StatusCode, fmt.Errorf("unable to %s %q: %s", method, u, resp.Status))
+	}
+	return resp, nil
+}
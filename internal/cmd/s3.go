@@ -0,0 +1,227 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3 static website hosting has no rewrites, so the registry is written with
+// the exact layout and each object's content type is set on upload.
+
+type awsCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+func (cmd *generateCmd) generateS3(ctx context.Context, rd registryData, srv server) error {
+	return cmd.generateSite(ctx, rd, exactLayout, srv)
+}
+
+func (cmd *generateCmd) publishS3(ctx context.Context, srv server) error {
+	creds := awsCredentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return authError(fmt.Errorf("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are required to upload to bucket %q", srv.Bucket))
+	}
+
+	endpoint := srv.Endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", srv.Region)
+	}
+
+	cmd.ui.Info(fmt.Sprintf("\t[s3] uploading to bucket %q...", srv.Bucket))
+	// the prefix is the base path, so it is already part of the keys
+	return cmd.publishRemote(srv, remoteStore{
+		get: func(name string) ([]byte, error) {
+			return getS3Object(ctx, cmd.httpClient, endpoint, srv.Region, creds, srv.Bucket, name)
+		},
+		put: func(name string, body []byte) error {
+			return putS3Object(ctx, cmd.httpClient, endpoint, srv.Region, creds, srv.Bucket, name, siteContentType(name), body)
+		},
+		del: func(name string) error {
+			return deleteS3Object(ctx, cmd.httpClient, endpoint, srv.Region, creds, srv.Bucket, name)
+		},
+	})
+}
+
+// getS3Object downloads an object.
+func getS3Object(ctx context.Context, client *http.Client, endpoint, region string, creds awsCredentials, bucket, key string) ([]byte, error) {
+	resp, err := s3Request(ctx, client, http.MethodGet, endpoint, region, creds, bucket, key, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to download object %q: %w", key, err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, upstreamError(fmt.Errorf("unable to download object %q: %w", key, err))
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return respBody, nil
+	case http.StatusNotFound:
+		return nil, fmt.Errorf("unable to download object %q: %w", key, errNotFound)
+	}
+	return nil, statusError(resp.StatusCode, fmt.Errorf("unable to download object %q, status %d: %s", key, resp.StatusCode, respBody))
+}
+
+// putS3Object uploads an object.
+func putS3Object(ctx context.Context, client *http.Client, endpoint, region string, creds awsCredentials, bucket, key, contentType string, body []byte) error {
+	resp, err := s3Request(ctx, client, http.MethodPut, endpoint, region, creds, bucket, key, map[string]string{
+		"cache-control": "public, max-age=300",
+		"content-type":  contentType,
+	}, body)
+	if err != nil {
+		return fmt.Errorf("unable to upload object %q: %w", key, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := ioutil.ReadAll(resp.Body)
+		return statusError(resp.StatusCode, fmt.Errorf("unable to upload object %q, status %d: %s", key, resp.StatusCode, respBody))
+	}
+	return nil
+}
+
+// deleteS3Object deletes an object, S3 does not report objects that do not
+// exist.
+func deleteS3Object(ctx context.Context, client *http.Client, endpoint, region string, creds awsCredentials, bucket, key string) error {
+	resp, err := s3Request(ctx, client, http.MethodDelete, endpoint, region, creds, bucket, key, nil, nil)
+	if err != nil {
+		return fmt.Errorf("unable to delete object %q: %w", key, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		respBody, _ := ioutil.ReadAll(resp.Body)
+		return statusError(resp.StatusCode, fmt.Errorf("unable to delete object %q, status %d: %s", key, resp.StatusCode, respBody))
+	}
+	return nil
+}
+
+// s3Request sends a path style request signed with Signature Version 4, see
+// https://docs.aws.amazon.com/AmazonS3/latest/API/sig-v4-header-based-auth.html
+func s3Request(ctx context.Context, client *http.Client, method, endpoint, region string, creds awsCredentials, bucket, key string, extraHeaders map[string]string, body []byte) (*http.Response, error) {
+	u, err := url.Parse(strings.TrimSuffix(endpoint, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse endpoint: %w", err)
+	}
+	escapedPath := awsURIEncode("/" + bucket + "/" + key)
+	u.RawPath = escapedPath
+	u.Path, err = url.PathUnescape(escapedPath)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("unable to create request: %w", err)
+	}
+
+	now := time.Now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+
+	headers := map[string]string{
+		"host":                 u.Host,
+		"x-amz-content-sha256": payloadHash,
+		"x-amz-date":           amzDate,
+	}
+	for k, v := range extraHeaders {
+		headers[k] = v
+	}
+	if creds.SessionToken != "" {
+		headers["x-amz-security-token"] = creds.SessionToken
+	}
+
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + strings.TrimSpace(headers[k]) + "\n")
+		if k != "host" {
+			req.Header.Set(k, headers[k])
+		}
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		method,
+		escapedPath,
+		"", // query string
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := fmt.Sprintf("%s/%s/s3/aws4_request", date, region)
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	signingKey := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), date)
+	signingKey = hmacSHA256(signingKey, region)
+	signingKey = hmacSHA256(signingKey, "s3")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.AccessKeyID, scope, signedHeaders, signature,
+	))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, upstreamError(err)
+	}
+	return resp, nil
+}
+
+// awsURIEncode escapes everything but unreserved characters and slashes.
+func awsURIEncode(s string) string {
+	var b strings.Builder
+	for _, c := range []byte(s) {
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func sha256Hex(b []byte) string {
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
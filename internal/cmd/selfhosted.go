@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Self hosted servers use the same .json layout as Netlify, with a generated
+// configuration snippet for the rewrites and headers. Extended API and module
+// download documents are found by trying the path with a .json extension or
+// an index.json file.
+
+const nginxConfig = `# generated by tfstaticregistry, include this in a server block
+root %s;
+
+location = /.well-known/terraform.json {
+	add_header Cache-Control "public, max-age=300";
+	add_header Access-Control-Allow-Origin "*";
+}
+
+location /providers/v1/ {
+	rewrite "^/providers/v1/([^/]+)/([^/]+)/([^/]+)/download/([^/]+)/([^/]+)$" /providers/v1/$1/$2/$3-$4-$5.json last;
+
+	add_header Cache-Control "public, max-age=300";
+	add_header Access-Control-Allow-Origin "*";
+	try_files $uri $uri.json $uri/index.json =404;
+}
+
+location /modules/v1/ {
+	add_header Cache-Control "public, max-age=300";
+	add_header Access-Control-Allow-Origin "*";
+	try_files $uri $uri.json =404;
+}
+`
+
+const apacheConfig = `# generated by tfstaticregistry, place this in the document root
+AddType application/json .json
+DirectorySlash Off
+
+RewriteEngine On
+
+RewriteRule "^providers/v1/([^/]+)/([^/]+)/([^/]+)/download/([^/]+)/([^/]+)$" "providers/v1/$1/$2/$3-$4-$5.json" [L]
+
+RewriteCond "%{REQUEST_FILENAME}" !-f
+RewriteCond "%{REQUEST_FILENAME}.json" -f
+RewriteRule "^(providers|modules)/v1/(.+)$" "$1/v1/$2.json" [L]
+
+RewriteCond "%{REQUEST_FILENAME}" -d
+RewriteCond "%{REQUEST_FILENAME}/index.json" -f
+RewriteRule "^providers/v1/(.+?)/?$" "providers/v1/$1/index.json" [L]
+
+<IfModule mod_headers.c>
+	<FilesMatch "\.json$">
+		Header set Cache-Control "public, max-age=300"
+		Header set Access-Control-Allow-Origin "*"
+	</FilesMatch>
+</IfModule>
+`
+
+const caddyConfig = `# generated by tfstaticregistry, include this in a site block
+root * %s
+
+@download path_regexp download ^/providers/v1/([^/]+)/([^/]+)/([^/]+)/download/([^/]+)/([^/]+)$
+rewrite @download /providers/v1/{re.download.1}/{re.download.2}/{re.download.3}-{re.download.4}-{re.download.5}.json
+
+@registry path /.well-known/terraform.json /providers/v1/* /modules/v1/*
+header @registry {
+	Cache-Control "public, max-age=300"
+	Access-Control-Allow-Origin "*"
+}
+
+try_files {path} {path}.json {path}/index.json
+file_server
+`
+
+func (cmd *generateCmd) generateSelfHosted(ctx context.Context, rd registryData, srv server) error {
+	err := cmd.generateSite(ctx, rd, rewriteLayout, srv)
+	if err != nil {
+		return err
+	}
+
+	// the configuration points at the final output, not the staging directory
+	root, err := filepath.Abs(srv.targetDir())
+	if err != nil {
+		return err
+	}
+
+	// the .htaccess rules are relative to the directory they are in, so it
+	// is written to the registry directory instead of the host root
+	dir, file, config := srv.Output, "", ""
+	switch srv.Type {
+	case "nginx":
+		file, config = "nginx.conf", srv.prefixPaths(fmt.Sprintf(nginxConfig, root))
+	case "apache":
+		dir, file, config = srv.registryDir(), ".htaccess", apacheConfig
+	case "caddy":
+		file, config = "Caddyfile", srv.prefixPaths(fmt.Sprintf(caddyConfig, root))
+	default:
+		return fmt.Errorf("server type %q not supported", srv.Type)
+	}
+
+	// Terraform before 0.13.2 only reads the module download location from
+	// the X-Terraform-Get header, not the response body
+	config += selfHostedModuleHeaders(srv.Type, moduleHeaderRules(rd, srv))
+
+	cmd.ui.Info(fmt.Sprintf("\t[%s] writing %s...", srv.Type, file))
+	err = cmd.writeFile(filepath.Join(dir, file), []byte(strings.TrimLeft(config, "\n")))
+	if err != nil {
+		return fmt.Errorf("unable to write %s: %w", file, err)
+	}
+	return nil
+}
+
+// selfHostedModuleHeaders returns the configuration setting the X-Terraform-Get
+// header of each module download.
+func selfHostedModuleHeaders(serverType string, rules []headerRule) string {
+	if len(rules) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	switch serverType {
+	case "apache":
+		b.WriteString("\n<IfModule mod_headers.c>\n")
+	default:
+		b.WriteString("\n")
+	}
+
+	for i, r := range rules {
+		location := strings.ReplaceAll(r.Headers[0][1], `"`, `\"`)
+		switch serverType {
+		case "nginx":
+			fmt.Fprintf(&b, "location = %s {\n", r.Path)
+			fmt.Fprintf(&b, "\tadd_header X-Terraform-Get \"%s\";\n", location)
+			b.WriteString("\tadd_header Cache-Control \"public, max-age=300\";\n")
+			b.WriteString("\tadd_header Access-Control-Allow-Origin \"*\";\n")
+			b.WriteString("\ttry_files $uri.json =404;\n")
+			b.WriteString("}\n\n")
+		case "apache":
+			// the request URI is the rewritten one once the .json file is served
+			fmt.Fprintf(&b, "\t<If \"%%{REQUEST_URI} in { '%s', '%s.json' }\">\n", r.Path, r.Path)
+			fmt.Fprintf(&b, "\t\tHeader set X-Terraform-Get \"%s\"\n", location)
+			b.WriteString("\t</If>\n")
+		case "caddy":
+			fmt.Fprintf(&b, "@module%d path %s\n", i, r.Path)
+			fmt.Fprintf(&b, "header @module%d X-Terraform-Get \"%s\"\n\n", i, location)
+		}
+	}
+
+	if serverType == "apache" {
+		b.WriteString("</IfModule>\n")
+	}
+	return b.String()
+}
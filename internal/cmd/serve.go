@@ -0,0 +1,220 @@
+package cmd
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+)
+
+type serveCmd struct {
+	commonCmd
+
+	addr      string
+	dir       string
+	tokenFile string
+	basePath  string
+}
+
+func (cmd *serveCmd) Synopsis() string {
+	return "serves a generated static registry"
+}
+
+func (cmd *serveCmd) Help() string {
+	return `Usage: tfstaticregistry serve [-addr :8080] [-dir dist] [-token-file tokens.txt] [-base-path /terraform]
+
+  Serves a generated registry, applying the same rewrites as the static
+  server configurations. If a token file is given, every request except
+  service discovery requires one of its bearer tokens. Tokens issued by the
+  login.v1 service are not verified, only the tokens in the file.`
+}
+
+func (cmd *serveCmd) Flags() *flag.FlagSet {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	fs.StringVar(&cmd.addr, "addr", ":8080", "address to listen on")
+	fs.StringVar(&cmd.dir, "dir", "dist", "directory of the generated registry")
+	fs.StringVar(&cmd.tokenFile, "token-file", "", "file of accepted bearer tokens, one per line")
+	fs.StringVar(&cmd.basePath, "base-path", "", "path of the registry on the host, if generated with a base path")
+	return fs
+}
+
+func (cmd *serveCmd) Run(args []string) int {
+	fs := cmd.Flags()
+	err := fs.Parse(args)
+	if err != nil {
+		cmd.ui.Error(fmt.Sprintf("unable to parse flags: %s", err))
+		return exitCodeConfig
+	}
+
+	return cmd.run(cmd.runInternal)
+}
+
+func (cmd *serveCmd) runInternal() error {
+	var tokens []string
+	if cmd.tokenFile != "" {
+		var err error
+		tokens, err = readTokenFile(cmd.tokenFile)
+		if err != nil {
+			return configError(err)
+		}
+		if len(tokens) == 0 {
+			return configError(fmt.Errorf("no tokens found in %q", cmd.tokenFile))
+		}
+	}
+
+	cmd.ui.Info(fmt.Sprintf("Serving %q on %s\nTokens:\t%d", cmd.dir, cmd.addr, len(tokens)))
+	if len(tokens) == 0 && advertisesLogin(cmd.dir) {
+		cmd.ui.Warn("login.v1 is advertised in service discovery, but no token file was given so every request is allowed")
+	}
+
+	basePath := strings.Trim(cmd.basePath, "/")
+	if basePath != "" {
+		basePath = "/" + basePath
+	}
+
+	return http.ListenAndServe(cmd.addr, registryHandler(cmd.dir, basePath, tokens))
+}
+
+func readTokenFile(file string) ([]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open token file %q: %w", file, err)
+	}
+	defer f.Close()
+
+	tokens := []string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		tokens = append(tokens, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read token file %q: %w", file, err)
+	}
+	return tokens, nil
+}
+
+var registryRewrites = []struct {
+	re          *regexp.Regexp
+	replacement string
+}{
+	{regexp.MustCompile(`^(/providers/v1/[^/]+/[^/]+)/versions$`), "$1/versions.json"},
+	{regexp.MustCompile(`^(/providers/v1/[^/]+/[^/]+)/([^/]+)/download/([^/]+)/([^/]+)$`), "$1/$2-$3-$4.json"},
+	{regexp.MustCompile(`^(/providers/v1/[^/]+/[^/]+)/([^/]+)$`), "$1/$2.json"},
+	{regexp.MustCompile(`^(/providers/v1/[^/]+/[^/]+|/providers/v1/[^/]+)/?$`), "$1/index.json"},
+	{regexp.MustCompile(`^(/modules/v1/[^/]+/[^/]+/[^/]+)/versions$`), "$1/versions.json"},
+	{regexp.MustCompile(`^(/modules/v1/[^/]+/[^/]+/[^/]+/[^/]+/download)$`), "$1.json"},
+}
+
+// rewriteRegistryPath maps a registry protocol path to the generated file
+// serving it, the same as the rewrites in the static server configurations.
+func rewriteRegistryPath(p string) string {
+	if strings.HasSuffix(p, ".json") {
+		return p
+	}
+	for _, rw := range registryRewrites {
+		if rw.re.MatchString(p) {
+			return rw.re.ReplaceAllString(p, rw.replacement)
+		}
+	}
+	return p
+}
+
+// advertisesLogin reports whether the service discovery document in dir has
+// a login.v1 service.
+func advertisesLogin(dir string) bool {
+	f, err := http.Dir(dir).Open("/.well-known/terraform.json")
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	var wk wellKnownTerraform
+	if err := json.NewDecoder(f).Decode(&wk); err != nil {
+		return false
+	}
+	return wk.LoginV1 != nil
+}
+
+// noListingFS hides directories without an index.html, so the file server
+// does not list them.
+type noListingFS struct {
+	fs http.FileSystem
+}
+
+func (nfs noListingFS) Open(name string) (http.File, error) {
+	f, err := nfs.fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if fi.IsDir() {
+		index, err := nfs.fs.Open(path.Join(name, "index.html"))
+		if err != nil {
+			f.Close()
+			return nil, os.ErrNotExist
+		}
+		index.Close()
+	}
+	return f, nil
+}
+
+func registryHandler(dir, basePath string, tokens []string) http.Handler {
+	files := http.FileServer(noListingFS{http.Dir(dir)})
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// discovery is always public so that terraform login can find the login service
+		if len(tokens) > 0 && r.URL.Path != "/.well-known/terraform.json" && !validBearerToken(r, tokens) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		p := r.URL.Path
+		if rel := strings.TrimPrefix(p, basePath); basePath == "" || rel != p {
+			p = basePath + rewriteRegistryPath(rel)
+		}
+
+		if strings.HasPrefix(p, basePath+"/modules/v1/") && strings.HasSuffix(p, "/download.json") {
+			// open through http.Dir so the path can't escape the directory
+			if f, err := http.Dir(dir).Open(path.Clean(p)); err == nil {
+				var d moduleDownload
+				if err := json.NewDecoder(f).Decode(&d); err == nil {
+					w.Header().Set("X-Terraform-Get", d.Location)
+				}
+				f.Close()
+			}
+		}
+
+		r2 := r.Clone(r.Context())
+		r2.URL.Path = p
+		files.ServeHTTP(w, r2)
+	})
+}
+
+func validBearerToken(r *http.Request, tokens []string) bool {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return false
+	}
+	token := strings.TrimPrefix(auth, "Bearer ")
+	for _, t := range tokens {
+		if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			return true
+		}
+	}
+	return false
+}
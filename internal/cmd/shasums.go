@@ -18,15 +18,23 @@ type shasum struct {
 func downloadSHASUMS(ctx context.Context, client *http.Client, url string) ([]shasum, error) {
 	resp, err := client.Get(url)
 	if err != nil {
-		return nil, fmt.Errorf("unable to GET SHASUMS file: %w", err)
+		return nil, upstreamError(fmt.Errorf("unable to GET SHASUMS file: %w", err))
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode != http.StatusOK {
+		return nil, statusError(resp.StatusCode, fmt.Errorf("unexpected status code %d for %q", resp.StatusCode, url))
+	}
+
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
 		return nil, fmt.Errorf("unable to read SHASUMS body: %w", err)
 	}
 
+	return parseSHASUMS(body)
+}
+
+func parseSHASUMS(body []byte) ([]shasum, error) {
 	scanner := bufio.NewScanner(bytes.NewBuffer(body))
 	sums := []shasum{}
 	for scanner.Scan() {
@@ -0,0 +1,214 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// siteLayout determines where registry documents are written in a static site.
+type siteLayout int
+
+const (
+	// rewriteLayout writes documents as .json files, the server rewrites the
+	// protocol paths to them
+	rewriteLayout siteLayout = iota
+
+	// exactLayout writes documents at their protocol paths, for servers
+	// without rewrites, the server must serve them as JSON
+	exactLayout
+)
+
+func providerVersionsPath(k providerVersionsKey) string {
+	return path.Join(
+		"providers/v1",
+		strings.ToLower(k.Namespace), strings.ToLower(k.Name),
+		"versions",
+	)
+}
+
+func providerDownloadPath(k providerDownloadKey) string {
+	return path.Join(
+		"providers/v1",
+		strings.ToLower(k.Namespace), strings.ToLower(k.Name),
+		k.Version, "download", k.OS, k.Arch,
+	)
+}
+
+func moduleVersionsPath(k moduleVersionsKey) string {
+	return path.Join(
+		"modules/v1",
+		strings.ToLower(k.Namespace), strings.ToLower(k.Name), strings.ToLower(k.System),
+		"versions",
+	)
+}
+
+func moduleDownloadPath(k moduleDownloadKey) string {
+	return path.Join(
+		"modules/v1",
+		strings.ToLower(k.Namespace), strings.ToLower(k.Name), strings.ToLower(k.System),
+		k.Version, "download",
+	)
+}
+
+func (l siteLayout) providerVersionsFile(k providerVersionsKey) string {
+	if l == exactLayout {
+		return providerVersionsPath(k)
+	}
+	return providerVersionsPath(k) + ".json"
+}
+
+func (l siteLayout) providerDownloadFile(k providerDownloadKey) string {
+	if l == exactLayout {
+		return providerDownloadPath(k)
+	}
+	return path.Join(
+		"providers/v1",
+		strings.ToLower(k.Namespace), strings.ToLower(k.Name),
+		fmt.Sprintf("%s-%s-%s.json", k.Version, k.OS, k.Arch),
+	)
+}
+
+func (l siteLayout) moduleVersionsFile(k moduleVersionsKey) string {
+	if l == exactLayout {
+		return moduleVersionsPath(k)
+	}
+	return moduleVersionsPath(k) + ".json"
+}
+
+func (l siteLayout) moduleDownloadFile(k moduleDownloadKey) string {
+	if l == exactLayout {
+		return moduleDownloadPath(k)
+	}
+	return moduleDownloadPath(k) + ".json"
+}
+
+// generateSite writes the registry documents shared by all static site
+// server types, server specific configuration is written by the caller.
+func (cmd *generateCmd) generateSite(ctx context.Context, rd registryData, layout siteLayout, srv server) error {
+	serverType, dir := srv.Type, srv.registryDir()
+
+	// service discovery is always at the root of the host
+	cmd.ui.Info(fmt.Sprintf("\t[%s] writing service discovery file...", serverType))
+	wk := wellKnownTerraform{
+		ProvidersV1: srv.BaseURL + srv.urlPath("providers/v1/"),
+		LoginV1:     rd.Login,
+	}
+	if len(rd.ModuleVersions) > 0 {
+		wk.ModulesV1 = srv.BaseURL + srv.urlPath("modules/v1/")
+	}
+	err := cmd.writeJSONFile(filepath.Join(srv.Output, ".well-known/terraform.json"), wk)
+	if err != nil {
+		return fmt.Errorf("unable to write service discovery file: %w", err)
+	}
+
+	cmd.ui.Info(fmt.Sprintf("\t[%s] writing provider version files", serverType))
+	for k, v := range rd.ProviderVersions {
+		err = cmd.writeJSONFile(filepath.Join(dir, filepath.FromSlash(layout.providerVersionsFile(k))), v)
+		if err != nil {
+			return err
+		}
+	}
+
+	cmd.ui.Info(fmt.Sprintf("\t[%s] writing provider version download files...", serverType))
+	for k, v := range rd.Downloads {
+		err = cmd.writeJSONFile(filepath.Join(dir, filepath.FromSlash(layout.providerDownloadFile(k))), v)
+		if err != nil {
+			return err
+		}
+	}
+
+	if cmd.fullAPI {
+		if layout == exactLayout {
+			// the metadata paths are also directories of the protocol paths
+			cmd.ui.Warn(fmt.Sprintf("\t[%s] the extended registry API requires rewrites, skipping", serverType))
+		} else {
+			cmd.ui.Info(fmt.Sprintf("\t[%s] writing provider metadata files...", serverType))
+			err = cmd.generateProviderAPI(ctx, rd, dir)
+			if err != nil {
+				return fmt.Errorf("unable to write provider metadata files: %w", err)
+			}
+		}
+	}
+
+	if cmd.docs {
+		cmd.ui.Info(fmt.Sprintf("\t[%s] writing provider documentation pages...", serverType))
+		err = cmd.generateProviderDocs(ctx, rd, dir)
+		if err != nil {
+			return fmt.Errorf("unable to write provider documentation pages: %w", err)
+		}
+	}
+
+	cmd.ui.Info(fmt.Sprintf("\t[%s] writing HTML index pages...", serverType))
+	err = cmd.generateHTML(ctx, rd, srv)
+	if err != nil {
+		return fmt.Errorf("unable to write HTML index pages: %w", err)
+	}
+
+	cmd.ui.Info(fmt.Sprintf("\t[%s] writing module version files...", serverType))
+	for k, v := range rd.ModuleVersions {
+		err = cmd.writeJSONFile(filepath.Join(dir, filepath.FromSlash(layout.moduleVersionsFile(k))), v)
+		if err != nil {
+			return err
+		}
+	}
+
+	cmd.ui.Info(fmt.Sprintf("\t[%s] writing module download files...", serverType))
+	for k, v := range rd.ModuleDownloads {
+		err = cmd.writeJSONFile(filepath.Join(dir, filepath.FromSlash(layout.moduleDownloadFile(k))), v)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// registryDir is the directory the registry documents are written to, the
+// output directory is the root of the host.
+func (srv server) registryDir() string {
+	return filepath.Join(srv.Output, filepath.FromSlash(srv.BasePath))
+}
+
+// urlPath returns the absolute URL path of p, relative to the base path.
+func (srv server) urlPath(p string) string {
+	return srv.BasePath + "/" + p
+}
+
+// prefixPaths adds the base path to the registry paths in a server
+// configuration.
+func (srv server) prefixPaths(config string) string {
+	if srv.BasePath == "" {
+		return config
+	}
+	return strings.NewReplacer(
+		"/providers/v1/", srv.BasePath+"/providers/v1/",
+		"/modules/v1/", srv.BasePath+"/modules/v1/",
+	).Replace(config)
+}
+
+func (cmd *generateCmd) writeJSONFile(file string, data interface{}) error {
+	bytes, err := json.MarshalIndent(data, "", "\t")
+	if err != nil {
+		return fmt.Errorf("unable to marshal JSON to write to file %q: %w", file, err)
+	}
+	return cmd.writeFile(file, bytes)
+}
+
+func (cmd *generateCmd) writeFile(file string, bytes []byte) error {
+	dir := filepath.Dir(file)
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return fmt.Errorf("unable to make directory %q: %w", dir, err)
+	}
+	err = replaceFile(file, bytes, 0644)
+	if err != nil {
+		return fmt.Errorf("unable to write file %q: %w", file, err)
+	}
+	cmd.markWritten(file)
+	return nil
+}
@@ -0,0 +1,278 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// targetDir is the output directory the server is finally written to.
+func (srv server) targetDir() string {
+	if srv.target != "" {
+		return srv.target
+	}
+	return srv.Output
+}
+
+// stageOutput creates a staging directory next to the output directory with
+// a copy of its current contents, the returned server writes to it. Files are
+// hard linked where possible, see replaceFile.
+func stageOutput(srv server) (server, error) {
+	out := filepath.Clean(srv.Output)
+	parent, base := filepath.Split(out)
+	if parent == "" {
+		parent = "."
+	}
+
+	staging, err := ioutil.TempDir(parent, "."+base+"-staging-")
+	if err != nil {
+		return server{}, fmt.Errorf("unable to create staging directory: %w", err)
+	}
+	err = os.Chmod(staging, 0755)
+	if err != nil {
+		os.RemoveAll(staging)
+		return server{}, err
+	}
+
+	// the output may be a symlink to the previous release
+	src, err := filepath.EvalSymlinks(out)
+	if err != nil {
+		src = out
+	}
+
+	// files not managed by the tool are carried over, and previous downloads
+	// are reused by the filesystem mirror
+	err = copyDir(src, staging)
+	if err != nil && !os.IsNotExist(err) {
+		os.RemoveAll(staging)
+		return server{}, fmt.Errorf("unable to copy %q to staging directory: %w", out, err)
+	}
+
+	srv.target = out
+	srv.Output = staging
+	return srv, nil
+}
+
+// canStage reports whether the output directory can be swapped, it must not
+// contain the working directory.
+func canStage(cwd string, srv server) bool {
+	out, err := filepath.Abs(srv.Output)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(out, cwd)
+	if err != nil {
+		return true
+	}
+	return rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// validateStaging checks the generated registry documents are complete
+// before the staging directory replaces the output directory.
+func (cmd *generateCmd) validateStaging(srv server) error {
+	if srv.Format == "registry" {
+		file := filepath.Join(srv.Output, ".well-known/terraform.json")
+		if !cmd.written[filepath.Clean(file)] {
+			return fmt.Errorf("service discovery file %q was not written", file)
+		}
+	}
+
+	dir := srv.registryDir()
+	for f := range cmd.written {
+		_, err := os.Stat(f)
+		if err != nil {
+			return fmt.Errorf("unable to find written file %q: %w", f, err)
+		}
+
+		rel, err := filepath.Rel(dir, f)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		isDocument := strings.HasPrefix(rel, "providers/v1/") || strings.HasPrefix(rel, "modules/v1/")
+		if srv.Format != "registry" || !isDocument || filepath.Ext(rel) == ".html" {
+			continue
+		}
+
+		body, err := ioutil.ReadFile(f)
+		if err != nil {
+			return fmt.Errorf("unable to read %q: %w", f, err)
+		}
+		if !json.Valid(body) {
+			return fmt.Errorf("file %q is not valid JSON", f)
+		}
+	}
+	return nil
+}
+
+// swapStaging atomically replaces the output directory with the staging
+// directory. A symlinked output is flipped to a new release directory,
+// otherwise the two directories are exchanged. Where exchanging is not
+// supported the output is moved aside first and briefly does not exist.
+func (cmd *generateCmd) swapStaging(srv server) error {
+	staging, out := srv.Output, srv.target
+
+	if fi, err := os.Lstat(out); err == nil && fi.Mode()&os.ModeSymlink != 0 {
+		return flipSymlink(staging, out)
+	}
+
+	backup := ""
+	_, err := os.Stat(out)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return err
+	default:
+		err = exchangeDirs(staging, out)
+		if err == nil {
+			// the staging directory now holds the previous output
+			err = os.RemoveAll(staging)
+			if err != nil {
+				return fmt.Errorf("unable to remove previous output %q: %w", staging, err)
+			}
+			return nil
+		}
+		cmd.ui.Warn(fmt.Sprintf("\t[%s] unable to swap %q atomically, moving it aside instead: %s", srv.Type, out, err))
+
+		backup = staging + "-previous"
+		err = os.Rename(out, backup)
+		if err != nil {
+			return fmt.Errorf("unable to move %q aside: %w", out, err)
+		}
+	}
+
+	err = os.Rename(staging, out)
+	if err != nil {
+		if backup != "" {
+			// restore the previous output so nothing is lost
+			os.Rename(backup, out)
+		}
+		return fmt.Errorf("unable to move staging directory to %q: %w", out, err)
+	}
+
+	if backup != "" {
+		err = os.RemoveAll(backup)
+		if err != nil {
+			return fmt.Errorf("unable to remove previous output %q: %w", backup, err)
+		}
+	}
+	return nil
+}
+
+// flipSymlink moves the staging directory to a release directory next to the
+// output symlink, then atomically points the symlink at it. The previous
+// release is removed if it was created by the tool.
+func flipSymlink(staging, out string) error {
+	parent, base := filepath.Split(out)
+	prefix := "." + base + "-release-"
+	release := filepath.Join(parent, prefix+strings.TrimPrefix(filepath.Base(staging), "."+base+"-staging-"))
+
+	previous, err := os.Readlink(out)
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(staging, release)
+	if err != nil {
+		return fmt.Errorf("unable to move staging directory to %q: %w", release, err)
+	}
+
+	// renaming a new symlink over the old one replaces it atomically
+	tmp := out + "-link"
+	os.Remove(tmp)
+	err = os.Symlink(filepath.Base(release), tmp)
+	if err == nil {
+		err = os.Rename(tmp, out)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("unable to point %q at %q: %w", out, release, err)
+	}
+
+	if !filepath.IsAbs(previous) {
+		previous = filepath.Join(parent, previous)
+	}
+	if strings.HasPrefix(filepath.Base(previous), prefix) && filepath.Dir(previous) == filepath.Clean(parent) {
+		err = os.RemoveAll(previous)
+		if err != nil {
+			return fmt.Errorf("unable to remove previous release %q: %w", previous, err)
+		}
+	}
+	return nil
+}
+
+// replaceFile writes a file, replacing rather than overwriting an existing
+// one. The staging directory hard links the previous output, so writing in
+// place would change the files being served.
+func replaceFile(file string, body []byte, perm os.FileMode) error {
+	err := os.Remove(file)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return ioutil.WriteFile(file, body, perm)
+}
+
+// copyDir copies the contents of src into the existing directory dst, hard
+// linking files where possible.
+func copyDir(src, dst string) error {
+	_, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+
+	return filepath.Walk(src, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		target := filepath.Join(dst, rel)
+
+		switch {
+		case info.IsDir():
+			return os.Mkdir(target, info.Mode().Perm())
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case !info.Mode().IsRegular():
+			return nil
+		}
+
+		return copyFile(p, target, info.Mode().Perm())
+	})
+}
+
+func copyFile(src, dst string, perm os.FileMode) error {
+	if os.Link(src, dst) == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,14 @@
+//go:build linux
+// +build linux
+
+package cmd
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// exchangeDirs atomically swaps two directories with renameat2, some
+// filesystems do not support it.
+func exchangeDirs(a, b string) error {
+	return unix.Renameat2(unix.AT_FDCWD, a, unix.AT_FDCWD, b, unix.RENAME_EXCHANGE)
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+// +build !linux
+
+package cmd
+
+import (
+	"errors"
+)
+
+// exchangeDirs is only supported on Linux, see stage_linux.go.
+func exchangeDirs(a, b string) error {
+	return errors.New("atomic exchange is only supported on Linux")
+}
@@ -0,0 +1,360 @@
+package cmd
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/hashicorp/go-version"
+	"github.com/hashicorp/hcl/v2"
+	"golang.org/x/crypto/openpgp"
+)
+
+type validateCmd struct {
+	commonCmd
+}
+
+func (cmd *validateCmd) Synopsis() string {
+	return "validates the registry configuration"
+}
+
+func (cmd *validateCmd) Help() string {
+	return `Usage: tfstaticregistry validate [-config registry.hcl] [-var name=value]
+
+  Validates the configuration without making any network requests, reporting
+  every problem found along with its location in the file.`
+}
+
+func (cmd *validateCmd) Flags() *flag.FlagSet {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	cmd.configFlags(fs)
+	return fs
+}
+
+func (cmd *validateCmd) Run(args []string) int {
+	fs := cmd.Flags()
+	err := fs.Parse(args)
+	if err != nil {
+		cmd.ui.Error(fmt.Sprintf("unable to parse flags: %s", err))
+		return exitCodeConfig
+	}
+
+	return cmd.run(cmd.runInternal)
+}
+
+func (cmd *validateCmd) runInternal() error {
+	_, err := cmd.loadConfig()
+	if err != nil {
+		return err
+	}
+	cmd.ui.Info(fmt.Sprintf("%s is valid.", cmd.configPath))
+	return nil
+}
+
+var configSchema = &hcl.BodySchema{
+	Blocks: []hcl.BlockHeaderSchema{
+		{Type: "provider", LabelNames: []string{"namespace", "name"}},
+		{Type: "module", LabelNames: []string{"namespace", "name", "system"}},
+		{Type: "login"},
+		{Type: "server", LabelNames: []string{"type"}},
+	},
+}
+
+var providerSchema = &hcl.BodySchema{
+	Attributes: []hcl.AttributeSchema{
+		{Name: "on_invalid_release"},
+	},
+	Blocks: []hcl.BlockHeaderSchema{
+		{Type: "manual"},
+		{Type: "github"},
+		{Type: "registry"},
+	},
+}
+
+var moduleSchema = &hcl.BodySchema{
+	Attributes: []hcl.AttributeSchema{
+		{Name: "on_invalid_release"},
+	},
+	Blocks: []hcl.BlockHeaderSchema{
+		{Type: "github"},
+		{Type: "archive"},
+	},
+}
+
+// validateConfig checks the decoded configuration, the body is only used for
+// the source ranges of the diagnostics. Blocks are decoded in order, so they
+// line up with the decoded slices.
+func validateConfig(body hcl.Body, conf config) hcl.Diagnostics {
+	var diags hcl.Diagnostics
+	content, _, _ := body.PartialContent(configSchema)
+	blocks := content.Blocks.ByType()
+
+	seen := map[string]*hcl.Block{}
+	for i, p := range conf.Providers {
+		block := blocks["provider"][i]
+		diags = append(diags, validateProvider(block, p)...)
+
+		k := strings.ToLower(p.String())
+		if prev, ok := seen[k]; ok {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Duplicate provider block",
+				Detail:   fmt.Sprintf("Provider %q was already declared at %s.", p, prev.DefRange),
+				Subject:  block.DefRange.Ptr(),
+			})
+		}
+		seen[k] = block
+	}
+
+	seen = map[string]*hcl.Block{}
+	for i, m := range conf.Modules {
+		block := blocks["module"][i]
+		diags = append(diags, validateModule(block, m)...)
+
+		k := strings.ToLower(m.String())
+		if prev, ok := seen[k]; ok {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Duplicate module block",
+				Detail:   fmt.Sprintf("Module %q was already declared at %s.", m, prev.DefRange),
+				Subject:  block.DefRange.Ptr(),
+			})
+		}
+		seen[k] = block
+	}
+
+	if conf.Login != nil {
+		err := conf.Login.Validate()
+		if err != nil {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid login block",
+				Detail:   capitalize(err.Error()) + ".",
+				Subject:  blocks["login"][0].DefRange.Ptr(),
+			})
+		}
+	}
+
+	for i, srv := range conf.Servers {
+		err := srv.Validate()
+		if err != nil {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid server block",
+				Detail:   capitalize(err.Error()) + ".",
+				Subject:  blocks["server"][i].DefRange.Ptr(),
+			})
+		}
+	}
+
+	return diags
+}
+
+func validateProvider(block *hcl.Block, p provider) hcl.Diagnostics {
+	var diags hcl.Diagnostics
+	for i, l := range block.Labels {
+		if l == "" {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid provider label",
+				Detail:   fmt.Sprintf("A blank %s is not allowed.", configSchema.Blocks[0].LabelNames[i]),
+				Subject:  block.LabelRanges[i].Ptr(),
+			})
+		}
+	}
+
+	content, _, _ := block.Body.PartialContent(providerSchema)
+
+	diags = append(diags, validateOnInvalidRelease(content, p.OnInvalidRelease)...)
+
+	sources := content.Blocks
+	switch {
+	case len(sources) == 0:
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Missing provider source",
+			Detail:   fmt.Sprintf("A source block of github, registry, or manual is required for provider %q.", p),
+			Subject:  block.DefRange.Ptr(),
+		})
+	case len(sources) > 1:
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Multiple provider sources",
+			Detail:   fmt.Sprintf("Provider %q already has a %s source at %s, only one source block is allowed.", p, sources[0].Type, sources[0].DefRange),
+			Subject:  sources[1].DefRange.Ptr(),
+		})
+	}
+
+	for _, src := range sources {
+		attrs, _ := src.Body.JustAttributes()
+		switch src.Type {
+		case "github":
+			if p.GitHub == nil {
+				continue
+			}
+			diags = append(diags, validateRepository(attrs["repository"], p.GitHub.Repository)...)
+			diags = append(diags, validatePublicKeyFile(attrs["public_key_file"], p.GitHub.PublicKeyFile)...)
+		case "registry":
+			if p.Registry == nil {
+				continue
+			}
+			parts := strings.Split(p.Registry.Source, "/")
+			if (len(parts) != 2 && len(parts) != 3) || contains(parts, "") {
+				diags = append(diags, &hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Malformed registry source",
+					Detail:   fmt.Sprintf("The source %q must be of the form [hostname/]namespace/name.", p.Registry.Source),
+					Subject:  attrs["source"].Expr.Range().Ptr(),
+				})
+			}
+		}
+	}
+
+	return diags
+}
+
+func validateOnInvalidRelease(content *hcl.BodyContent, mode string) hcl.Diagnostics {
+	attr, ok := content.Attributes["on_invalid_release"]
+	if !ok {
+		return nil
+	}
+	switch mode {
+	case "error", "warn", "ignore":
+		return nil
+	}
+	return hcl.Diagnostics{&hcl.Diagnostic{
+		Severity: hcl.DiagError,
+		Summary:  "Invalid on_invalid_release value",
+		Detail:   fmt.Sprintf("The value %q must be one of error, warn, or ignore.", mode),
+		Subject:  attr.Expr.Range().Ptr(),
+	}}
+}
+
+func validateModule(block *hcl.Block, m module) hcl.Diagnostics {
+	var diags hcl.Diagnostics
+	if m.Namespace == "" || m.Name == "" || m.System == "" {
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid module label",
+			Detail:   "A blank namespace, name, or system is not allowed.",
+			Subject:  block.DefRange.Ptr(),
+		})
+	}
+
+	content, _, _ := block.Body.PartialContent(moduleSchema)
+	diags = append(diags, validateOnInvalidRelease(content, m.OnInvalidRelease)...)
+
+	sources := content.Blocks.ByType()
+
+	switch {
+	case len(content.Blocks) == 0:
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Missing module source",
+			Detail:   fmt.Sprintf("A source block of github or archive is required for module %q.", m),
+			Subject:  block.DefRange.Ptr(),
+		})
+	case len(sources["github"]) > 0 && len(sources["archive"]) > 0:
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Multiple module sources",
+			Detail:   fmt.Sprintf("Module %q already has a github source at %s, archive blocks are not allowed with it.", m, sources["github"][0].DefRange),
+			Subject:  sources["archive"][0].DefRange.Ptr(),
+		})
+	}
+
+	if m.GitHub != nil {
+		attrs, _ := sources["github"][0].Body.JustAttributes()
+		diags = append(diags, validateRepository(attrs["repository"], m.GitHub.Repository)...)
+	}
+
+	versions := map[string]bool{}
+	for i, a := range m.Archives {
+		attrs, _ := sources["archive"][i].Body.JustAttributes()
+		v, err := version.NewSemver(a.Version)
+		if err != nil {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid module version",
+				Detail:   fmt.Sprintf("The version %q is not valid semver.", a.Version),
+				Subject:  attrs["version"].Expr.Range().Ptr(),
+			})
+		} else if versions[v.String()] {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Duplicate module version",
+				Detail:   fmt.Sprintf("An archive for version %q was already declared.", a.Version),
+				Subject:  sources["archive"][i].DefRange.Ptr(),
+			})
+		} else {
+			versions[v.String()] = true
+		}
+
+		if u, err := url.Parse(a.URL); err != nil || u.Scheme == "" {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid archive URL",
+				Detail:   fmt.Sprintf("The URL %q must be an absolute URL.", a.URL),
+				Subject:  attrs["url"].Expr.Range().Ptr(),
+			})
+		}
+	}
+
+	return diags
+}
+
+func validateRepository(attr *hcl.Attribute, repository string) hcl.Diagnostics {
+	parts := strings.Split(repository, "/")
+	if len(parts) == 2 && parts[0] != "" && parts[1] != "" && !strings.ContainsAny(repository, " \t") {
+		return nil
+	}
+	return hcl.Diagnostics{{
+		Severity: hcl.DiagError,
+		Summary:  "Malformed GitHub repository",
+		Detail:   fmt.Sprintf("The repository %q must be of the form owner/name.", repository),
+		Subject:  attr.Expr.Range().Ptr(),
+	}}
+}
+
+func validatePublicKeyFile(attr *hcl.Attribute, file string) hcl.Diagnostics {
+	diag := &hcl.Diagnostic{
+		Severity: hcl.DiagError,
+		Subject:  attr.Expr.Range().Ptr(),
+	}
+
+	keyRingData, err := ioutil.ReadFile(file)
+	switch {
+	case os.IsNotExist(err):
+		diag.Summary = "Missing public key file"
+		diag.Detail = fmt.Sprintf("The public key file %q does not exist.", file)
+		return hcl.Diagnostics{diag}
+	case err != nil:
+		diag.Summary = "Unable to read public key file"
+		diag.Detail = fmt.Sprintf("Unable to read %q: %s.", file, err)
+		return hcl.Diagnostics{diag}
+	}
+
+	keyRing, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(keyRingData))
+	switch {
+	case err != nil:
+		diag.Summary = "Invalid public key file"
+		diag.Detail = fmt.Sprintf("Unable to read the armored key ring in %q: %s.", file, err)
+		return hcl.Diagnostics{diag}
+	case len(keyRing) != 1:
+		diag.Summary = "Invalid public key file"
+		diag.Detail = fmt.Sprintf("Expected 1 key in %q, got %d.", file, len(keyRing))
+		return hcl.Diagnostics{diag}
+	}
+	return nil
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}